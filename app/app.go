@@ -1,8 +1,23 @@
 package app
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
 	"strings"
 
+	"github.com/tendermint/basecoin/metrics"
+	"github.com/tendermint/basecoin/plugins/coingov"
+	"github.com/tendermint/basecoin/plugins/escrow"
+	eyesplugin "github.com/tendermint/basecoin/plugins/eyes"
+	"github.com/tendermint/basecoin/plugins/faucet"
+	"github.com/tendermint/basecoin/plugins/htlc"
+	"github.com/tendermint/basecoin/plugins/issue"
+	"github.com/tendermint/basecoin/plugins/names"
+	"github.com/tendermint/basecoin/plugins/paychan"
+	"github.com/tendermint/basecoin/plugins/stake"
 	sm "github.com/tendermint/basecoin/state"
 	"github.com/tendermint/basecoin/types"
 	. "github.com/tendermint/go-common"
@@ -14,15 +29,31 @@ import (
 
 const (
 	version   = "0.1"
-	maxTxSize = 10240
+	maxTxSize = 10240 // Protocol limit; the config can only lower it for CheckTx
 
-	PluginTypeByteBase = 0x01
-	PluginTypeByteEyes = 0x02
-	PluginTypeByteGov  = 0x03
+	PluginTypeByteBase    = 0x01
+	PluginTypeByteEyes    = 0x02
+	PluginTypeByteGov     = 0x03
+	PluginTypeByteEscrow  = 0x04
+	PluginTypeByteHTLC    = 0x05
+	PluginTypeByteIssue   = 0x06
+	PluginTypeByteStake   = 0x07
+	PluginTypeByteNames   = 0x08
+	PluginTypeBytePaychan = 0x09
+	PluginTypeByteCoingov = 0x0A
+	PluginTypeByteFaucet  = 0x0B
 
-	PluginNameBase = "base"
-	PluginNameEyes = "eyes"
-	PluginNameGov  = "gov"
+	PluginNameBase    = "base"
+	PluginNameEyes    = "eyes"
+	PluginNameGov     = "gov"
+	PluginNameEscrow  = "escrow"
+	PluginNameHTLC    = "htlc"
+	PluginNameIssue   = "issue"
+	PluginNameStake   = "stake"
+	PluginNameNames   = "names"
+	PluginNamePaychan = "paychan"
+	PluginNameCoingov = "coingov"
+	PluginNameFaucet  = "faucet"
 )
 
 type Basecoin struct {
@@ -31,25 +62,169 @@ type Basecoin struct {
 	state      *sm.State
 	cacheState *sm.State
 	plugins    *types.Plugins
+	migrations map[string]map[int]types.Migration
+	migrated   bool
+	lastCommit LastCommit
+	commitFile string // optional
+	config     *Config
+	metrics    *metrics.Metrics
+}
+
+// The block height and app hash of the last Commit.
+type LastCommit struct {
+	Height  uint64 `json:"height"`
+	AppHash []byte `json:"app_hash"`
 }
 
 func NewBasecoin(eyesCli *eyes.Client) *Basecoin {
+	app, err := NewBasecoinWithConfig(eyesCli, DefaultConfig())
+	if err != nil {
+		PanicSanity("Default config should be valid: " + err.Error())
+	}
+	return app
+}
+
+// Creates the app with every plugin. Which plugins are enabled is
+// part of the state, set with the "base/plugins" genesis option.
+// Disabled plugins take no txs or queries and skip the block hooks.
+func NewBasecoinWithConfig(eyesCli *eyes.Client, config *Config) (*Basecoin, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	govMint := gov.NewGovernmint()
-	state := sm.NewState(eyesCli)
-	plugins := types.NewPlugins()
-	plugins.RegisterPlugin(PluginTypeByteGov, PluginNameGov, govMint)
-	return &Basecoin{
+	state := sm.NewState(sm.NewIndexedKVStore(eyesCli))
+	app := &Basecoin{
 		eyesCli:    eyesCli,
 		govMint:    govMint,
 		state:      state,
 		cacheState: nil,
-		plugins:    plugins,
+		plugins:    types.NewPlugins(),
+		migrations: make(map[string]map[int]types.Migration),
+		config:     config,
+		metrics:    metrics.NewMetrics(),
+	}
+	// Without a commit file, the state is the only record of a past commit.
+	app.lastCommit.Height = sm.GetLastHeight(state)
+	app.plugins.RegisterPlugin(PluginTypeByteEyes, PluginNameEyes, eyesplugin.New(PluginNameEyes))
+	app.plugins.RegisterPlugin(PluginTypeByteGov, PluginNameGov, refundingPlugin{govMint, PluginNameGov})
+	app.plugins.RegisterPlugin(PluginTypeByteEscrow, PluginNameEscrow, escrow.New(PluginNameEscrow))
+	app.plugins.RegisterPlugin(PluginTypeByteHTLC, PluginNameHTLC, htlc.New(PluginNameHTLC))
+	app.plugins.RegisterPlugin(PluginTypeByteIssue, PluginNameIssue, issue.New(PluginNameIssue))
+	app.plugins.RegisterPlugin(PluginTypeByteStake, PluginNameStake, stake.New(PluginNameStake))
+	app.plugins.RegisterPlugin(PluginTypeByteNames, PluginNameNames, names.New(PluginNameNames))
+	app.plugins.RegisterPlugin(PluginTypeBytePaychan, PluginNamePaychan, paychan.New(PluginNamePaychan))
+	app.plugins.RegisterPlugin(PluginTypeByteFaucet, PluginNameFaucet, faucet.New(PluginNameFaucet))
+	app.plugins.RegisterPlugin(PluginTypeByteCoingov, PluginNameCoingov, coingov.New(PluginNameCoingov, app.setPluginOption))
+	for _, name := range config.Plugins {
+		if app.plugins.GetByName(name) == nil {
+			return nil, errors.New("Unknown plugin in config: " + name)
+		}
+	}
+	return app, nil
+}
+
+// Checks that the chain enables the plugins the config expects.
+// Call it once genesis is set. The chain's set is part of the state,
+// so a node whose config differs stops here rather than fork.
+func (app *Basecoin) CheckEnabledPlugins() error {
+	if len(app.config.Plugins) == 0 {
+		return nil
+	}
+	enabled := app.pluginNames()
+	if !sameNames(enabled, app.config.Plugins) {
+		return errors.New(Fmt("Config expects plugins %v, but the chain enables %v",
+			app.config.Plugins, enabled))
+	}
+	return nil
+}
+
+// Registers a migration that upgrades the named plugin's state from
+// fromVersion to fromVersion+1. Migrations run at the first BeginBlock
+// for every plugin whose Version() is ahead of the version in state.
+func (app *Basecoin) RegisterMigration(name string, fromVersion int, migration types.Migration) {
+	if app.migrations[name] == nil {
+		app.migrations[name] = make(map[int]types.Migration)
 	}
+	app.migrations[name][fromVersion] = migration
+}
+
+// Takes the last commit from the commit file at path, and keeps that
+// file up to date from now on. MerkleEyes must be at the recorded height.
+// Nothing is committed to read the app hash, so writes a crashed block
+// left in a remote MerkleEyes are never made permanent here.
+// A missing file is created at the next Commit, but only a fresh chain
+// may start without one, as the app hash could not be reported.
+func (app *Basecoin) LoadLastCommit(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if height := sm.GetLastHeight(app.state); height != 0 {
+			return errors.New(Fmt("MerkleEyes is at height %v, but there is no commit file at %v with its app hash",
+				height, path))
+		}
+		app.commitFile = path
+		return nil
+	}
+	if err := app.ReadLastCommit(path); err != nil {
+		return err
+	}
+	app.commitFile = path
+	app.metrics.SetHeight(app.lastCommit.Height)
+	return nil
+}
+
+// Takes the last commit from the commit file at path, without committing
+// to get the app hash, for tools that read the state of a stopped node.
+func (app *Basecoin) ReadLastCommit(path string) error {
+	recorded, err := readCommitFile(path)
+	if err != nil {
+		return err
+	}
+	if height := sm.GetLastHeight(app.state); recorded.Height != height {
+		return errors.New(Fmt("MerkleEyes is at height %v, but the last commit was at height %v",
+			height, recorded.Height))
+	}
+	app.lastCommit = recorded
+	return nil
+}
+
+func readCommitFile(path string) (LastCommit, error) {
+	var recorded LastCommit
+	data, err := ReadFile(path)
+	if err != nil {
+		return recorded, err
+	}
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return recorded, errors.New("Error decoding commit file: " + err.Error())
+	}
+	return recorded, nil
+}
+
+func (app *Basecoin) LastCommit() LastCommit {
+	return app.lastCommit
+}
+
+func (app *Basecoin) Metrics() *metrics.Metrics {
+	return app.metrics
 }
 
 // TMSP::Info
 func (app *Basecoin) Info() string {
-	return Fmt("Basecoin v%v", version)
+	return Fmt("Basecoin v%v height:%v app_hash:%X max_tx_size:%v min_fee:%v plugins:%v",
+		version, app.lastCommit.Height, app.lastCommit.AppHash,
+		app.config.MaxTxSize, app.config.MinFee, app.pluginNames())
+}
+
+// Sets a "plugin/key" option on store, for proposals that passed.
+// Basecoin's own options can only be set at genesis.
+func (app *Basecoin) setPluginOption(store types.KVStore, key string, value string) (log string) {
+	pluginName, key := splitKey(key)
+	if pluginName == PluginNameBase {
+		return "Cannot change base option " + key
+	}
+	plugin := app.plugins.GetByName(pluginName)
+	if plugin == nil {
+		return "Invalid plugin name: " + pluginName
+	}
+	return plugin.SetOption(store, key, value)
 }
 
 // TMSP::SetOption
@@ -68,6 +243,24 @@ func (app *Basecoin) SetOption(key string, value string) (log string) {
 		case "chainID":
 			app.state.SetChainID(value)
 			return "Success"
+		case "store":
+			// Sets a raw key, as written by Export().
+			var err error
+			var entry *StoreEntry
+			wire.ReadJSONPtr(&entry, []byte(value), &err)
+			if err != nil {
+				return "Error decoding store entry: " + err.Error()
+			}
+			if entry == nil || len(entry.Key) == 0 {
+				return "Store entry must have a key"
+			}
+			if bytes.Equal(entry.Key, sm.ChainIDKey()) {
+				// The state keeps the chain ID in memory too.
+				app.state.SetChainID(string(entry.Value))
+				return "Success"
+			}
+			app.state.Set(entry.Key, entry.Value)
+			return "Success"
 		case "account":
 			var err error
 			var acc *types.Account
@@ -75,7 +268,47 @@ func (app *Basecoin) SetOption(key string, value string) (log string) {
 			if err != nil {
 				return "Error decoding acc message: " + err.Error()
 			}
-			app.state.SetAccount(acc.PubKey.Address(), acc)
+			app.setGenesisAccount(acc)
+			return "Success"
+		case "vesting_account":
+			var err error
+			var vestingAcc *types.VestingAccount
+			wire.ReadJSONPtr(&vestingAcc, []byte(value), &err)
+			if err != nil {
+				return "Error decoding vesting account message: " + err.Error()
+			}
+			acc, schedule := vestingAcc.Account, vestingAcc.Schedule
+			if acc == nil || schedule == nil {
+				return "Vesting account must have an account and a schedule"
+			}
+			if err := schedule.ValidateBasic(); err != nil {
+				return "Invalid vesting schedule: " + err.Error()
+			}
+			if !acc.Balance.IsGTE(schedule.Coins) {
+				return Fmt("Cannot vest %v out of a balance of %v", schedule.Coins, acc.Balance)
+			}
+			app.setGenesisAccount(acc)
+			sm.SetVestingSchedule(app.state, acc.PubKey.Address(), schedule)
+			return "Success"
+		case "inflation_rate":
+			rate, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || rate > 1000000000 {
+				return "Invalid inflation rate " + value
+			}
+			sm.SetInflationRate(app.state, rate)
+			return "Success"
+		case "inflation_denom":
+			sm.SetInflationDenom(app.state, value)
+			return "Success"
+		case "plugins":
+			// A comma separated list of the enabled plugins.
+			names := strings.Split(value, ",")
+			for _, name := range names {
+				if app.plugins.GetByName(name) == nil {
+					return "Unknown plugin " + name
+				}
+			}
+			sm.SetEnabledPlugins(app.state, names)
 			return "Success"
 		}
 		return "Unrecognized option key " + key
@@ -83,7 +316,9 @@ func (app *Basecoin) SetOption(key string, value string) (log string) {
 }
 
 // TMSP::AppendTx
+// Nothing from the config may decide the result, or nodes would disagree.
 func (app *Basecoin) AppendTx(txBytes []byte) (res tmsp.Result) {
+	defer func() { app.metrics.CountTx("append_tx", res.Code) }()
 	if len(txBytes) > maxTxSize {
 		return tmsp.ErrBaseEncodingError.AppendLog("Tx size exceeds maximum")
 	}
@@ -94,28 +329,34 @@ func (app *Basecoin) AppendTx(txBytes []byte) (res tmsp.Result) {
 		return tmsp.ErrBaseEncodingError.AppendLog("Error decoding tx: " + err.Error())
 	}
 	// Validate and exec tx
-	res = sm.ExecTx(app.state, app.plugins, tx, false, nil)
+	res = sm.ExecTx(app.state, app.plugins, tx, false, app.metrics)
 	if res.IsErr() {
 		return res.PrependLog("Error in AppendTx")
 	}
-	return tmsp.OK
+	return res
 }
 
 // TMSP::CheckTx
 func (app *Basecoin) CheckTx(txBytes []byte) (res tmsp.Result) {
-	if len(txBytes) > maxTxSize {
-		return tmsp.ErrBaseEncodingError.AppendLog("Tx size exceeds maximum")
-	}
+	defer func() { app.metrics.CountTx("check_tx", res.Code) }()
 	// Decode tx
 	var tx types.Tx
 	err := wire.ReadBinaryBytes(txBytes, &tx)
 	if err != nil {
-		return tmsp.ErrBaseEncodingError.AppendLog("Error decoding tx: " + err.Error())
+		return app.rejectTx("decode_error", tmsp.ErrBaseEncodingError.AppendLog("Error decoding tx: "+err.Error()))
+	}
+	// Mempool policy, not checked in AppendTx
+	if len(txBytes) > app.config.MaxTxSize {
+		return app.rejectTx("tx_too_large", tmsp.ErrBaseEncodingError.AppendLog("Tx size exceeds maximum"))
+	}
+	if fee := txFee(tx); fee < app.config.MinFee {
+		return app.rejectTx("fee_too_low", tmsp.ErrBaseInsufficientFunds.AppendLog(
+			Fmt("Fee %v is below the minimum of %v", fee, app.config.MinFee)))
 	}
 	// Validate tx
-	res = sm.ExecTx(app.cacheState, app.plugins, tx, true, nil)
+	res = sm.ExecTx(app.cacheState, app.plugins, tx, true, app.metrics)
 	if res.IsErr() {
-		return res.PrependLog("Error in CheckTx")
+		return app.rejectTx("invalid_tx", res.PrependLog("Error in CheckTx"))
 	}
 	return tmsp.OK
 }
@@ -129,50 +370,238 @@ func (app *Basecoin) Query(query []byte) (res tmsp.Result) {
 	query = query[1:]
 	switch typeByte {
 	case PluginTypeByteBase:
-		return tmsp.OK.SetLog("This type of query not yet supported")
+		return app.queryAccount(query)
 	case PluginTypeByteEyes:
 		return app.eyesCli.QuerySync(query)
 	}
+	if querier, ok := app.plugins.GetByByte(typeByte).(types.Querier); ok {
+		if !sm.IsPluginEnabled(app.state, app.plugins.GetNameByByte(typeByte)) {
+			return tmsp.ErrBaseUnknownPlugin.SetLog(
+				Fmt("Plugin with type byte %X is not enabled on this chain", typeByte))
+		}
+		return querier.Query(app.state, query)
+	}
 	return tmsp.ErrBaseUnknownPlugin.SetLog(
 		Fmt("Unknown plugin with type byte %X", typeByte))
 }
 
 // TMSP::Commit
 func (app *Basecoin) Commit() (res tmsp.Result) {
+	// Let plugins write out anything before the commit.
+	for _, plugin := range app.enabledPlugins() {
+		if committer, ok := plugin.Plugin.(types.Committer); ok {
+			committer.Commit(app.state)
+		}
+	}
+
+	// The height is part of the committed state.
+	height := app.state.GetBlockHeight()
+	sm.SetLastHeight(app.state, height)
+
 	// Commit eyes.
 	res = app.eyesCli.CommitSync()
 	if res.IsErr() {
 		PanicSanity("Error getting hash: " + res.Error())
 	}
+	app.lastCommit = LastCommit{
+		Height:  height,
+		AppHash: res.Data,
+	}
+	app.metrics.SetHeight(height)
+	if app.commitFile != "" {
+		data, err := json.Marshal(app.lastCommit)
+		if err != nil {
+			PanicSanity("Error encoding last commit: " + err.Error())
+		}
+		if err := WriteFileAtomic(app.commitFile, data, 0644); err != nil {
+			PanicCrisis("Error writing commit file: " + err.Error())
+		}
+	}
 	return res
 }
 
 // TMSP::InitChain
 func (app *Basecoin) InitChain(validators []*tmsp.Validator) {
 	for _, plugin := range app.plugins.GetList() {
-		plugin.Plugin.InitChain(app.state, validators)
+		if sm.IsPluginEnabled(app.state, plugin.Name) {
+			plugin.Plugin.InitChain(app.state, validators)
+		}
+		// Genesis state is written in the current format.
+		sm.SetPluginVersion(app.state, plugin.Name, pluginVersion(plugin.Plugin))
 	}
+	sm.SetValidators(app.state, validators)
+	app.migrated = true
 }
 
 // TMSP::BeginBlock
 func (app *Basecoin) BeginBlock(height uint64) {
-	for _, plugin := range app.plugins.GetList() {
+	app.state.SetBlockHeight(height)
+	if !app.migrated {
+		app.migratePlugins()
+		app.migrated = true
+	}
+	sm.PayBlockReward(app.state)
+	for _, plugin := range app.enabledPlugins() {
 		plugin.Plugin.BeginBlock(app.state, height)
 	}
+	if app.cacheState != nil {
+		app.metrics.AddCacheStats(app.cacheState.CacheStats())
+	}
 	app.cacheState = app.state.CacheWrap()
 }
 
 // TMSP::EndBlock
 func (app *Basecoin) EndBlock(height uint64) (diffs []*tmsp.Validator) {
-	for _, plugin := range app.plugins.GetList() {
+	for _, plugin := range app.enabledPlugins() {
 		moreDiffs := plugin.Plugin.EndBlock(app.state, height)
 		diffs = append(diffs, moreDiffs...)
 	}
+	sm.UpdateValidators(app.state, diffs)
+	// State that predates the key index can't be walked, so isn't checked.
+	period := app.config.InvariantCheckPeriod
+	if period != 0 && height%period == 0 && sm.CheckIndexComplete(app.state) == nil {
+		if err := sm.CheckSupply(app.state); err != nil {
+			// The state can't be trusted, so stop before committing it.
+			PanicCrisis(Fmt("At height %v: %v", height, err.Error()))
+		}
+	}
 	return
 }
 
 //----------------------------------------
 
+// Counts why CheckTx kept a tx out of the mempool.
+func (app *Basecoin) rejectTx(reason string, res tmsp.Result) tmsp.Result {
+	app.metrics.CountRejection(reason)
+	return res
+}
+
+// Sets an account from genesis, counting its balance in the supply.
+func (app *Basecoin) setGenesisAccount(acc *types.Account) {
+	addr := acc.PubKey.Address()
+	if old := app.state.GetAccount(addr); old != nil {
+		sm.SubtractSupply(app.state, old.Balance)
+	}
+	sm.AddSupply(app.state, acc.Balance)
+	app.state.SetAccount(addr, acc)
+}
+
+// Query is the address of an account.
+func (app *Basecoin) queryAccount(addr []byte) tmsp.Result {
+	acc := app.state.GetAccount(addr)
+	if acc == nil {
+		return tmsp.ErrBaseUnknownAddress.AppendLog(Fmt("Account %X not found", addr))
+	}
+	// The block height is 0 after a restart until the next block,
+	// so lock as of the last committed height.
+	var locked types.Coins
+	if schedule := sm.GetVestingSchedule(app.state, addr); schedule != nil {
+		locked = schedule.Locked(sm.GetLastHeight(app.state))
+	}
+	// Encoded as a pointer, as clients decode it.
+	balance := &types.AccountBalance{
+		Account:   acc,
+		Locked:    locked,
+		Spendable: acc.Balance.Minus(locked),
+	}
+	return tmsp.NewResultOK(wire.BinaryBytes(balance), "")
+}
+
+// Brings the state of each plugin up to its current version.
+func (app *Basecoin) migratePlugins() {
+	for _, plugin := range app.plugins.GetList() {
+		version := pluginVersion(plugin.Plugin)
+		stored := sm.GetPluginVersion(app.state, plugin.Name)
+		if stored == version {
+			continue
+		}
+		if stored > version {
+			PanicSanity(Fmt("State of plugin %v is at version %v, but the code is at version %v",
+				plugin.Name, stored, version))
+		}
+		cache := app.state.CacheWrap()
+		for v := stored; v < version; v++ {
+			migration := app.migrations[plugin.Name][v]
+			if migration == nil {
+				PanicSanity(Fmt("No migration registered for plugin %v from version %v", plugin.Name, v))
+			}
+			if err := migration(cache); err != nil {
+				PanicCrisis(Fmt("Error migrating plugin %v from version %v: %v", plugin.Name, v, err))
+			}
+		}
+		sm.SetPluginVersion(cache, plugin.Name, version)
+		cache.CacheSync()
+	}
+}
+
+func pluginVersion(plugin types.Plugin) int {
+	if versioner, ok := plugin.(types.Versioner); ok {
+		return versioner.Version()
+	}
+	return 0
+}
+
+// Returns the plugins enabled on this chain.
+func (app *Basecoin) enabledPlugins() (plugins []types.NamedPlugin) {
+	for _, plugin := range app.plugins.GetList() {
+		if sm.IsPluginEnabled(app.state, plugin.Name) {
+			plugins = append(plugins, plugin)
+		}
+	}
+	return plugins
+}
+
+// Returns the names of the plugins enabled on this chain.
+func (app *Basecoin) pluginNames() (names []string) {
+	for _, plugin := range app.enabledPlugins() {
+		names = append(names, plugin.Name)
+	}
+	return names
+}
+
+// refundingPlugin gives the coins of each call back to the caller, for
+// plugins like governmint that don't use them. ExecTx credits the coins
+// to the plugin account, so they would be stranded there otherwise.
+type refundingPlugin struct {
+	types.Plugin
+	name string
+}
+
+func (rp refundingPlugin) RunTx(store types.KVStore, ctx types.CallContext, txBytes []byte) tmsp.Result {
+	res := rp.Plugin.RunTx(store, ctx, txBytes)
+	if res.IsOK() && !sm.TransferCoins(store, types.PluginAddress(rp.name), ctx.Caller, ctx.Coins) {
+		PanicSanity("Plugin account should hold the call coins")
+	}
+	return res
+}
+
+// Returns whether a and b hold the same names, in any order.
+func sameNames(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool)
+	for _, name := range a {
+		set[name] = true
+	}
+	for _, name := range b {
+		if !set[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func txFee(tx types.Tx) int64 {
+	switch tx := tx.(type) {
+	case *types.SendTx:
+		return tx.Fee
+	case *types.AppTx:
+		return tx.Fee
+	}
+	return 0
+}
+
 // Splits the string at the first '/'.
 // if there are none, the second string is nil.
 func splitKey(key string) (prefix string, suffix string) {
@@ -0,0 +1,264 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/tests"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	eyescli "github.com/tendermint/merkleeyes/client"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+// A plugin at schema version 2 that counts its commits.
+type versionedPlugin struct {
+	commits int
+}
+
+func (vp *versionedPlugin) Version() int { return 2 }
+
+func (vp *versionedPlugin) Commit(store types.KVStore) { vp.commits += 1 }
+
+func (vp *versionedPlugin) SetOption(store types.KVStore, key string, value string) string {
+	return "Unrecognized option key " + key
+}
+func (vp *versionedPlugin) RunTx(store types.KVStore, ctx types.CallContext, txBytes []byte) tmsp.Result {
+	return tmsp.OK
+}
+func (vp *versionedPlugin) InitChain(store types.KVStore, vals []*tmsp.Validator) {}
+func (vp *versionedPlugin) BeginBlock(store types.KVStore, height uint64)         {}
+func (vp *versionedPlugin) EndBlock(store types.KVStore, height uint64) []*tmsp.Validator {
+	return nil
+}
+
+func TestMigratePlugins(t *testing.T) {
+	bcApp := NewBasecoin(eyescli.NewLocalClient())
+	bcApp.SetOption("base/chainID", "test_chain_id")
+	plugin := &versionedPlugin{}
+	bcApp.plugins.RegisterPlugin(0x7F, "versioned", plugin)
+
+	// State written by version 0 of the plugin, before a restart.
+	key := []byte("versioned/schema")
+	bcApp.state.Set(key, []byte("v0"))
+	bcApp.RegisterMigration("versioned", 0, func(store types.KVStore) error {
+		if string(store.Get(key)) != "v0" {
+			return errors.New("Expected v0 state")
+		}
+		store.Set(key, []byte("v1"))
+		return nil
+	})
+	bcApp.RegisterMigration("versioned", 1, func(store types.KVStore) error {
+		store.Set(key, []byte(string(store.Get(key))+"+v2"))
+		return nil
+	})
+
+	bcApp.BeginBlock(1)
+	if version := sm.GetPluginVersion(bcApp.state, "versioned"); version != 2 {
+		t.Errorf("Expected plugin state at version 2, got %v", version)
+	}
+	if value := string(bcApp.state.Get(key)); value != "v1+v2" {
+		t.Errorf("Expected both migrations to run in order, got %v", value)
+	}
+
+	// Migrations only run once.
+	bcApp.EndBlock(1)
+	bcApp.Commit()
+	bcApp.BeginBlock(2)
+	if value := string(bcApp.state.Get(key)); value != "v1+v2" {
+		t.Errorf("Expected migrations to run once, got %v", value)
+	}
+	if plugin.commits != 1 {
+		t.Errorf("Expected the plugin to see 1 commit, got %v", plugin.commits)
+	}
+}
+
+// A plugin that counts its block hooks and queries.
+type countingPlugin struct {
+	calls int
+}
+
+func (cp *countingPlugin) Commit(store types.KVStore) { cp.calls += 1 }
+
+func (cp *countingPlugin) Query(store types.KVStore, query []byte) tmsp.Result {
+	cp.calls += 1
+	return tmsp.OK
+}
+
+func (cp *countingPlugin) SetOption(store types.KVStore, key string, value string) string {
+	return "Unrecognized option key " + key
+}
+func (cp *countingPlugin) RunTx(store types.KVStore, ctx types.CallContext, txBytes []byte) tmsp.Result {
+	return tmsp.OK
+}
+func (cp *countingPlugin) InitChain(store types.KVStore, vals []*tmsp.Validator) { cp.calls += 1 }
+func (cp *countingPlugin) BeginBlock(store types.KVStore, height uint64)         { cp.calls += 1 }
+func (cp *countingPlugin) EndBlock(store types.KVStore, height uint64) []*tmsp.Validator {
+	cp.calls += 1
+	return nil
+}
+
+func TestDisabledPlugin(t *testing.T) {
+	config := DefaultConfig()
+	config.Plugins = []string{PluginNameEyes}
+	bcApp, err := NewBasecoinWithConfig(eyescli.NewLocalClient(), config)
+	if err != nil {
+		t.Fatalf("Unexpected error creating app: %v", err)
+	}
+	plugin := &countingPlugin{}
+	bcApp.plugins.RegisterPlugin(0x7F, "counting", plugin)
+	bcApp.SetOption("base/plugins", PluginNameEscrow)
+
+	bcApp.InitChain(nil)
+	bcApp.BeginBlock(1)
+	bcApp.EndBlock(1)
+	bcApp.Commit()
+	if res := bcApp.Query([]byte{0x7F}); res.Code != tmsp.CodeType_BaseUnknownPlugin {
+		t.Errorf("Expected a query to a disabled plugin to fail, got %v", res)
+	}
+	if plugin.calls != 0 {
+		t.Errorf("Expected a disabled plugin not to be called, got %v calls", plugin.calls)
+	}
+	if err := bcApp.CheckEnabledPlugins(); err == nil {
+		t.Error("Expected the config's plugins not to match the chain's")
+	}
+
+	config.Plugins = []string{"nonexistent"}
+	if _, err := NewBasecoinWithConfig(eyescli.NewLocalClient(), config); err == nil {
+		t.Error("Expected an unknown plugin in the config to fail")
+	}
+}
+
+func TestAppendTxMaxSize(t *testing.T) {
+	bcApp := NewBasecoin(eyescli.NewLocalClient())
+	res := bcApp.AppendTx(make([]byte, maxTxSize+1))
+	if res.Code != tmsp.CodeType_BaseEncodingError {
+		t.Errorf("Expected a tx over the protocol maximum to be refused, got %v", res)
+	}
+}
+
+func TestInvariantCheckSkipsUnindexedState(t *testing.T) {
+	eyesCli := eyescli.NewLocalClient()
+	// State written by a version without the key index.
+	sm.MintCoins(eyesCli, []byte("old_address_00000000"), types.Coins{{"", 100}})
+	sm.SetLastHeight(eyesCli, 7)
+	eyesCli.CommitSync()
+
+	config := DefaultConfig()
+	config.InvariantCheckPeriod = 1
+	bcApp, err := NewBasecoinWithConfig(eyesCli, config)
+	if err != nil {
+		t.Fatalf("Unexpected error creating app: %v", err)
+	}
+	bcApp.BeginBlock(8)
+	// The index sees the supply, but not the old account that holds it.
+	sm.MintCoins(bcApp.state, []byte("new_address_00000000"), types.Coins{{"", 10}})
+	bcApp.EndBlock(8)
+}
+
+func TestLoadLastCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "basecoin_commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	commitFile := path.Join(dir, "commit.json")
+
+	eyesCli := eyescli.NewLocalClient()
+	bcApp := NewBasecoin(eyesCli)
+	if err := bcApp.LoadLastCommit(commitFile); err != nil {
+		t.Fatalf("Unexpected error starting a fresh chain: %v", err)
+	}
+	bcApp.BeginBlock(1)
+	bcApp.EndBlock(1)
+	bcApp.Commit()
+	committed := bcApp.LastCommit()
+
+	// A crash in the middle of the next block leaves a write behind.
+	eyesCli.Set([]byte("pending"), []byte("1"))
+	restarted := NewBasecoin(eyesCli)
+	if err := restarted.LoadLastCommit(commitFile); err != nil {
+		t.Fatalf("Unexpected error loading the last commit: %v", err)
+	}
+	if got := restarted.LastCommit(); got.Height != 1 || !bytes.Equal(got.AppHash, committed.AppHash) {
+		t.Errorf("Expected the last commit %v, got %v", committed, got)
+	}
+	if !strings.Contains(restarted.Info(), Fmt("app_hash:%X", committed.AppHash)) {
+		t.Errorf("Expected Info to report the app hash, got %v", restarted.Info())
+	}
+	if res := eyesCli.QuerySync(append([]byte{0x01}, "pending"...)); len(res.Data) != 0 {
+		t.Error("Expected loading the last commit not to commit pending writes")
+	}
+
+	os.Remove(commitFile)
+	if err := NewBasecoin(eyesCli).LoadLastCommit(commitFile); err == nil {
+		t.Error("Expected a chain past height 0 without a commit file to fail")
+	}
+}
+
+func TestQueryVestingAccountAfterRestart(t *testing.T) {
+	eyesCli := eyescli.NewLocalClient()
+	bcApp := NewBasecoin(eyesCli)
+	acc := tests.PrivAccountFromSecret("vester").Account
+	acc.Balance = types.Coins{{"", 100}}
+	vestingAcc := &types.VestingAccount{
+		Account:  &acc,
+		Schedule: &types.VestingSchedule{EndHeight: 2, Cliff: true, Coins: types.Coins{{"", 80}}},
+	}
+	if log := bcApp.SetOption("base/vesting_account", string(wire.JSONBytes(vestingAcc))); log != "Success" {
+		t.Fatalf("Unexpected error setting vesting account: %v", log)
+	}
+	for height := uint64(1); height <= 2; height++ {
+		bcApp.BeginBlock(height)
+		bcApp.EndBlock(height)
+		bcApp.Commit()
+	}
+
+	// No block has begun since the restart.
+	restarted := NewBasecoin(eyesCli)
+	res := restarted.Query(append([]byte{PluginTypeByteBase}, acc.PubKey.Address()...))
+	var balance *types.AccountBalance
+	if err := wire.ReadBinaryBytes(res.Data, &balance); err != nil || balance == nil {
+		t.Fatalf("Unexpected query result %v: %v", res, err)
+	}
+	if !balance.Locked.IsZero() || !balance.Spendable.IsEqual(acc.Balance) {
+		t.Errorf("Expected the coins to have unlocked at height 2, got %v", balance)
+	}
+}
+
+func TestAppTxToGovRefunded(t *testing.T) {
+	bcApp := NewBasecoin(eyescli.NewLocalClient())
+	bcApp.SetOption("base/chainID", "test_chain_id")
+	privAcc := tests.PrivAccountFromSecret("voter")
+	acc := privAcc.Account
+	acc.Balance = types.Coins{{"", 100}}
+	bcApp.SetOption("base/account", string(wire.JSONBytes(&acc)))
+
+	tx := &types.AppTx{
+		Type: PluginTypeByteGov,
+		Input: types.TxInput{
+			Address:  acc.PubKey.Address(),
+			Coins:    types.Coins{{"", 10}},
+			Sequence: 1,
+			PubKey:   acc.PubKey,
+		},
+	}
+	tx.Input.Signature = privAcc.PrivKey.Sign(tx.SignBytes("test_chain_id"))
+	bcApp.BeginBlock(1)
+	if res := bcApp.AppendTx(wire.BinaryBytes(struct{ types.Tx }{tx})); res.IsErr() {
+		t.Fatalf("Unexpected error: %v", res)
+	}
+	if got := bcApp.state.GetAccount(acc.PubKey.Address()); !got.Balance.IsEqual(acc.Balance) {
+		t.Errorf("Expected the call coins back, got %v", got.Balance)
+	}
+	if got := bcApp.state.GetAccount(types.PluginAddress(PluginNameGov)); got != nil && !got.Balance.IsZero() {
+		t.Errorf("Expected nothing left with governmint, got %v", got.Balance)
+	}
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	. "github.com/tendermint/go-common"
+)
+
+const envPrefix = "BASECOIN_"
+
+var logLevels = []string{"debug", "info", "notice", "warn", "error", "crit"}
+
+// Config holds the limits and policies of the app, and where it
+// listens. Values come from a JSON file, then BASECOIN_ environment
+// variables, then flags.
+//
+// None of it decides what goes into a block, or nodes would disagree.
+// MaxTxSize and MinFee only keep txs out of the mempool. The enabled
+// plugins are part of the state, set at genesis, and Plugins only lists
+// the ones this node expects, so that it refuses to start on a chain
+// that enables others.
+type Config struct {
+	MaxTxSize  int      `json:"max_tx_size"` // In bytes, up to the protocol maximum
+	MinFee     int64    `json:"min_fee"`     // For CheckTx, in the default coin
+	Plugins    []string `json:"plugins"`     // Expected enabled plugins, or any if empty
+	LogLevel   string   `json:"log_level"`
+	Address    string   `json:"address"`     // TMSP listen address
+	Eyes       string   `json:"eyes"`        // MerkleEyes address, or "local"
+	Genesis    string   `json:"genesis"`     // Genesis file, if any
+	CommitFile string   `json:"commit_file"` // Last commit file, for the app hash
+	Metrics    string   `json:"metrics"`     // Metrics HTTP address, or "" for none
+
+	// Blocks between supply invariant checks, or 0 for none.
+	InvariantCheckPeriod uint64 `json:"invariant_check_period"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		MaxTxSize:  maxTxSize,
+		MinFee:     0,
+		LogLevel:   "info",
+		Address:    "tcp://0.0.0.0:46658",
+		Eyes:       "local",
+		CommitFile: "commit.json",
+	}
+}
+
+// Reads the config at filePath over the defaults.
+// A missing file gives the default config.
+func LoadConfig(filePath string) (*Config, error) {
+	config := DefaultConfig()
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return config, nil
+	}
+	data, err := ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, errors.New("Error decoding config: " + err.Error())
+	}
+	return config, nil
+}
+
+func WriteConfig(filePath string, config *Config) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFile(filePath, data, 0644)
+}
+
+// Overrides config values with any BASECOIN_ environment variables,
+// named after the JSON keys, like BASECOIN_MAX_TX_SIZE.
+// Plugins are separated by commas.
+func (config *Config) ApplyEnv() error {
+	for _, key := range []string{"max_tx_size", "min_fee", "plugins", "log_level",
+		"address", "eyes", "genesis", "commit_file", "metrics", "invariant_check_period"} {
+		value, ok := os.LookupEnv(envPrefix + strings.ToUpper(key))
+		if !ok {
+			continue
+		}
+		if err := config.Set(key, value); err != nil {
+			return errors.New(envPrefix + strings.ToUpper(key) + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// Sets the config value under its JSON key from a string.
+func (config *Config) Set(key string, value string) error {
+	switch key {
+	case "max_tx_size":
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("Invalid max tx size " + value)
+		}
+		config.MaxTxSize = size
+	case "min_fee":
+		fee, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("Invalid min fee " + value)
+		}
+		config.MinFee = fee
+	case "plugins":
+		config.Plugins = nil
+		for _, name := range strings.Split(value, ",") {
+			if name != "" {
+				config.Plugins = append(config.Plugins, name)
+			}
+		}
+	case "log_level":
+		config.LogLevel = value
+	case "address":
+		config.Address = value
+	case "eyes":
+		config.Eyes = value
+	case "genesis":
+		config.Genesis = value
+	case "commit_file":
+		config.CommitFile = value
+	case "metrics":
+		config.Metrics = value
+	case "invariant_check_period":
+		period, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return errors.New("Invalid invariant check period " + value)
+		}
+		config.InvariantCheckPeriod = period
+	default:
+		return errors.New("Unknown config key " + key)
+	}
+	return nil
+}
+
+// Checks the config values.
+func (config *Config) Validate() error {
+	if config.MaxTxSize <= 0 || config.MaxTxSize > maxTxSize {
+		return errors.New(Fmt("Max tx size must be between 1 and %v, got %v", maxTxSize, config.MaxTxSize))
+	}
+	if config.MinFee < 0 {
+		return errors.New(Fmt("Min fee cannot be negative, got %v", config.MinFee))
+	}
+	validLevel := false
+	for _, level := range logLevels {
+		if config.LogLevel == level {
+			validLevel = true
+		}
+	}
+	if !validLevel {
+		return errors.New(Fmt("Log level must be one of %v, got %v", logLevels, config.LogLevel))
+	}
+	if config.Address == "" {
+		return errors.New("Address cannot be empty")
+	}
+	if config.Eyes == "" {
+		return errors.New("Eyes address cannot be empty")
+	}
+	if config.CommitFile == "" {
+		return errors.New("Commit file cannot be empty, it records the app hash")
+	}
+	return nil
+}
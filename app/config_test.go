@@ -0,0 +1,74 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "basecoin_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configPath := path.Join(dir, "config.json")
+
+	// A missing file gives the defaults.
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Unexpected error loading a missing config: %v", err)
+	}
+	if config.MaxTxSize != DefaultConfig().MaxTxSize {
+		t.Errorf("Expected the default max tx size, got %v", config.MaxTxSize)
+	}
+
+	// The file overrides the defaults, and leaves the rest alone.
+	ioutil.WriteFile(configPath, []byte(`{"max_tx_size": 100, "min_fee": 2}`), 0600)
+	config, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Unexpected error loading config: %v", err)
+	}
+	if config.MaxTxSize != 100 || config.MinFee != 2 || config.LogLevel != "info" {
+		t.Errorf("Expected file values over the defaults, got %v", config)
+	}
+
+	// The environment overrides the file.
+	os.Setenv("BASECOIN_MIN_FEE", "5")
+	defer os.Unsetenv("BASECOIN_MIN_FEE")
+	if err := config.ApplyEnv(); err != nil {
+		t.Fatalf("Unexpected error applying env: %v", err)
+	}
+	if config.MaxTxSize != 100 || config.MinFee != 5 {
+		t.Errorf("Expected env values over the file, got %v", config)
+	}
+
+	// Flags, set last, override the environment.
+	if err := config.Set("min_fee", "7"); err != nil {
+		t.Fatalf("Unexpected error setting min fee: %v", err)
+	}
+	if config.MinFee != 7 {
+		t.Errorf("Expected the flag value, got %v", config.MinFee)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Unexpected invalid config: %v", err)
+	}
+
+	os.Setenv("BASECOIN_MIN_FEE", "lots")
+	if err := config.ApplyEnv(); err == nil {
+		t.Error("Expected an invalid env value to fail")
+	}
+	if err := config.Set("plugins", "eyes,htlc"); err != nil || len(config.Plugins) != 2 {
+		t.Errorf("Expected two plugins, got %v: %v", config.Plugins, err)
+	}
+	config.MaxTxSize = maxTxSize + 1
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a max tx size over the protocol's to be invalid")
+	}
+	config.MaxTxSize = maxTxSize
+	config.MinFee = -1
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a negative min fee to be invalid")
+	}
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/go-wire"
+)
+
+// A raw key of the state, set with the "base/store" option.
+type StoreEntry struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Returns a genesis that sets every key of the current state, accounts
+// and plugin state alike. The keys are set in the order they were first
+// written, which gives the tree the same shape and hence the same app hash.
+// Fails if the state has keys that were set before the key index existed.
+func (app *Basecoin) Export() (*Genesis, error) {
+	if err := sm.CheckIndexComplete(app.state); err != nil {
+		return nil, err
+	}
+	// The chain ID is in the state, setting it first could reorder the keys.
+	genesis := new(Genesis)
+	sm.IterateKeys(app.state, func(key []byte, value []byte) bool {
+		entry := StoreEntry{Key: key, Value: value}
+		genesis.Options = append(genesis.Options, KeyValue{
+			Key:   PluginNameBase + "/store",
+			Value: string(wire.JSONBytes(entry)),
+		})
+		return true
+	})
+	return genesis, nil
+}
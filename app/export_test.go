@@ -0,0 +1,59 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/tests"
+	"github.com/tendermint/basecoin/types"
+	"github.com/tendermint/go-wire"
+	eyescli "github.com/tendermint/merkleeyes/client"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	bcApp := NewBasecoin(eyescli.NewLocalClient())
+	bcApp.SetOption("base/chainID", "test_chain_id")
+	acc := tests.PrivAccountFromSecret("exporter").Account
+	acc.Balance = types.Coins{{"", 100}}
+	bcApp.SetOption("base/account", string(wire.JSONBytes(&acc)))
+	bcApp.BeginBlock(1)
+	bcApp.EndBlock(1)
+	bcApp.Commit()
+
+	genesis, err := bcApp.Export()
+	if err != nil {
+		t.Fatalf("Unexpected error exporting: %v", err)
+	}
+	if supply := genesis.TotalSupply(); !supply.IsEqual(acc.Balance) {
+		t.Errorf("Expected exported supply %v, got %v", acc.Balance, supply)
+	}
+
+	imported := NewBasecoin(eyescli.NewLocalClient())
+	if err := imported.SetGenesis(genesis); err != nil {
+		t.Fatalf("Unexpected error importing: %v", err)
+	}
+	res := imported.eyesCli.CommitSync()
+	if !bytes.Equal(res.Data, bcApp.LastCommit().AppHash) {
+		t.Errorf("Expected app hash %X after import, got %X", bcApp.LastCommit().AppHash, res.Data)
+	}
+	if chainID := imported.state.GetChainID(); chainID != "test_chain_id" {
+		t.Errorf("Expected the imported chain ID in memory, got %v", chainID)
+	}
+	if got := imported.state.GetAccount(acc.PubKey.Address()); got == nil || !got.Balance.IsEqual(acc.Balance) {
+		t.Errorf("Expected the imported account, got %v", got)
+	}
+}
+
+func TestExportRefusesUnindexedState(t *testing.T) {
+	eyesCli := eyescli.NewLocalClient()
+	// State written by a version without the key index.
+	eyesCli.Set(sm.ChainIDKey(), []byte("test_chain_id"))
+	sm.SetLastHeight(eyesCli, 7)
+	eyesCli.CommitSync()
+
+	bcApp := NewBasecoin(eyesCli)
+	if _, err := bcApp.Export(); err == nil {
+		t.Fatal("Expected exporting state that predates the index to fail")
+	}
+}
@@ -0,0 +1,275 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+)
+
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Genesis is the initial state of the chain, applied through SetOption
+// in the order of its fields. Options come last, so they can set anything
+// the typed fields don't cover.
+type Genesis struct {
+	ChainID       string            `json:"chain_id"`
+	Plugins       []string          `json:"plugins"`  // Enabled plugins, or all if empty
+	Accounts      []json.RawMessage `json:"accounts"` // Pub keys are read by go-wire
+	Denoms        []string          `json:"denoms"`   // Reserved from issuance
+	PluginOptions []PluginOptions   `json:"plugin_options"`
+	Options       []KeyValue        `json:"options"`
+}
+
+// Options for one plugin, keyed without the plugin name.
+type PluginOptions struct {
+	Plugin  string     `json:"plugin"`
+	Options []KeyValue `json:"options"`
+}
+
+func LoadGenesis(filePath string) (*Genesis, error) {
+	data, err := ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGenesis(data)
+}
+
+// Parses a genesis document, or a legacy [key1, value1, key2, value2, ...]
+// array, whose entries become Options.
+func ParseGenesis(data []byte) (*Genesis, error) {
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		kvz, err := parseLegacyGenesis(data)
+		if err != nil {
+			return nil, err
+		}
+		return &Genesis{Options: kvz}, nil
+	}
+	genesis := new(Genesis)
+	if err := json.Unmarshal(data, genesis); err != nil {
+		return nil, errors.New("Error parsing genesis: " + err.Error())
+	}
+	return genesis, nil
+}
+
+func parseLegacyGenesis(data []byte) (kvz []KeyValue, err error) {
+	kvz_ := []interface{}{}
+	if err := json.Unmarshal(data, &kvz_); err != nil {
+		return nil, errors.New("Error parsing genesis: " + err.Error())
+	}
+	if len(kvz_)%2 != 0 {
+		return nil, errors.New("Genesis cannot have an odd number of items. Format = [key1, value1, key2, value2, ...]")
+	}
+	for i := 0; i < len(kvz_); i += 2 {
+		keyIfc := kvz_[i]
+		valueIfc := kvz_[i+1]
+		var value string
+		key, ok := keyIfc.(string)
+		if !ok {
+			return nil, errors.New(Fmt("Genesis had invalid key %v of type %v", keyIfc, reflect.TypeOf(keyIfc)))
+		}
+		if value_, ok := valueIfc.(string); ok {
+			value = value_
+		} else {
+			valueBytes, err := json.Marshal(valueIfc)
+			if err != nil {
+				return nil, errors.New(Fmt("Genesis had invalid value %v: %v", valueIfc, err.Error()))
+			}
+			value = string(valueBytes)
+		}
+		kvz = append(kvz, KeyValue{key, value})
+	}
+	return kvz, nil
+}
+
+// Returns every option in the order it gets set.
+func (g *Genesis) KeyValues() (kvz []KeyValue) {
+	if g.ChainID != "" {
+		kvz = append(kvz, KeyValue{PluginNameBase + "/chainID", g.ChainID})
+	}
+	if len(g.Plugins) != 0 {
+		kvz = append(kvz, KeyValue{PluginNameBase + "/plugins", strings.Join(g.Plugins, ",")})
+	}
+	for _, acc := range g.Accounts {
+		kvz = append(kvz, KeyValue{PluginNameBase + "/account", string(acc)})
+	}
+	for _, denom := range g.Denoms {
+		kvz = append(kvz, KeyValue{PluginNameIssue + "/reserve", denom})
+	}
+	for _, pluginOptions := range g.PluginOptions {
+		for _, kv := range pluginOptions.Options {
+			kvz = append(kvz, KeyValue{pluginOptions.Plugin + "/" + kv.Key, kv.Value})
+		}
+	}
+	return append(kvz, g.Options...)
+}
+
+// Checks that the genesis sets a chain ID, that its accounts decode and
+// have valid balances, and that no address gets more than one account.
+func (g *Genesis) ValidateBasic() error {
+	hasChainID := false
+	addrs := make(map[string]bool)
+	for _, kv := range g.KeyValues() {
+		pluginName, key := splitKey(kv.Key)
+		if pluginName == "" || key == "" {
+			return errors.New("Invalid option key " + kv.Key)
+		}
+		if pluginName != PluginNameBase {
+			continue
+		}
+		if key == "chainID" {
+			if kv.Value == "" {
+				return errors.New("Chain ID cannot be empty")
+			}
+			hasChainID = true
+		}
+		if key == "store" {
+			var err error
+			var entry *StoreEntry
+			wire.ReadJSONPtr(&entry, []byte(kv.Value), &err)
+			if err != nil {
+				return errors.New("Error decoding store entry: " + err.Error())
+			}
+			if entry != nil && string(entry.Key) == string(sm.ChainIDKey()) && len(entry.Value) != 0 {
+				hasChainID = true
+			}
+			// An exported account is keyed by its address, and may have no pub key.
+			accountPrefix := sm.AccountKey(nil)
+			if entry == nil || len(entry.Value) == 0 || !bytes.HasPrefix(entry.Key, accountPrefix) {
+				continue
+			}
+			addr := entry.Key[len(accountPrefix):]
+			acc := storeEntryAccount(kv.Value)
+			if acc == nil {
+				return errors.New(Fmt("Error decoding genesis account %X", addr))
+			}
+			if err := checkGenesisAccount(addrs, addr, acc); err != nil {
+				return err
+			}
+			continue
+		}
+		acc, err := genesisAccount(key, kv.Value)
+		if err != nil {
+			return err
+		}
+		if acc == nil {
+			continue
+		}
+		if acc.PubKey == nil {
+			return errors.New("Genesis account must have a pub key")
+		}
+		if err := checkGenesisAccount(addrs, acc.PubKey.Address(), acc); err != nil {
+			return err
+		}
+	}
+	if !hasChainID {
+		return errors.New("Genesis must set a chain ID")
+	}
+	return nil
+}
+
+// Checks the balance of the account at addr, and that
+// no other account was seen there.
+func checkGenesisAccount(addrs map[string]bool, addr []byte, acc *types.Account) error {
+	if !acc.Balance.IsValid() {
+		return errors.New(Fmt("Genesis account %X has invalid coins %v", addr, acc.Balance))
+	}
+	if addrs[string(addr)] {
+		return errors.New(Fmt("Duplicate genesis account %X", addr))
+	}
+	addrs[string(addr)] = true
+	return nil
+}
+
+// Returns the sum of all genesis account balances, including
+// accounts set as raw store entries.
+// CONTRACT: ValidateBasic() passed.
+func (g *Genesis) TotalSupply() (supply types.Coins) {
+	for _, kv := range g.KeyValues() {
+		pluginName, key := splitKey(kv.Key)
+		if pluginName != PluginNameBase {
+			continue
+		}
+		if key == "store" {
+			if acc := storeEntryAccount(kv.Value); acc != nil {
+				supply = supply.Plus(acc.Balance)
+			}
+			continue
+		}
+		if acc, _ := genesisAccount(key, kv.Value); acc != nil {
+			supply = supply.Plus(acc.Balance)
+		}
+	}
+	return supply
+}
+
+// Returns the account in a raw store entry, or nil if the entry isn't one.
+func storeEntryAccount(value string) *types.Account {
+	var err error
+	var entry *StoreEntry
+	wire.ReadJSONPtr(&entry, []byte(value), &err)
+	if err != nil || entry == nil || len(entry.Value) == 0 ||
+		!strings.HasPrefix(string(entry.Key), string(sm.AccountKey(nil))) {
+		return nil
+	}
+	var acc *types.Account
+	if err := wire.ReadBinaryBytes(entry.Value, &acc); err != nil {
+		return nil
+	}
+	return acc
+}
+
+// Decodes the account set by a base option, or returns nil
+// if the option doesn't set one.
+func genesisAccount(key string, value string) (*types.Account, error) {
+	var err error
+	switch key {
+	case "account":
+		var acc *types.Account
+		wire.ReadJSONPtr(&acc, []byte(value), &err)
+		if err != nil {
+			return nil, errors.New("Error decoding genesis account: " + err.Error())
+		}
+		if acc == nil {
+			return nil, errors.New("Genesis account cannot be null")
+		}
+		return acc, nil
+	case "vesting_account":
+		var vestingAcc *types.VestingAccount
+		wire.ReadJSONPtr(&vestingAcc, []byte(value), &err)
+		if err != nil {
+			return nil, errors.New("Error decoding genesis vesting account: " + err.Error())
+		}
+		if vestingAcc == nil || vestingAcc.Account == nil {
+			return nil, errors.New("Genesis vesting account must have an account")
+		}
+		return vestingAcc.Account, nil
+	}
+	return nil, nil
+}
+
+// Validates and applies genesis, failing on the first option
+// that isn't set successfully, or if a block was already committed.
+func (app *Basecoin) SetGenesis(genesis *Genesis) error {
+	if height := sm.GetLastHeight(app.state); height > 0 {
+		return errors.New(Fmt("Genesis was already applied, the state is at height %v", height))
+	}
+	if err := genesis.ValidateBasic(); err != nil {
+		return err
+	}
+	for _, kv := range genesis.KeyValues() {
+		if log := app.SetOption(kv.Key, kv.Value); log != "Success" {
+			return errors.New(Fmt("Error setting %v=%v: %v", kv.Key, kv.Value, log))
+		}
+	}
+	return nil
+}
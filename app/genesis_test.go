@@ -0,0 +1,97 @@
+package app
+
+import (
+	"testing"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/tests"
+	"github.com/tendermint/basecoin/types"
+	"github.com/tendermint/go-wire"
+)
+
+func TestParseGenesis(t *testing.T) {
+	acc := tests.PrivAccountFromSecret("genesis").Account
+	acc.Balance = types.Coins{{"", 100}}
+	accJSON := string(wire.JSONBytes(&acc))
+
+	object := `{
+		"chain_id": "test_chain_id",
+		"accounts": [` + accJSON + `],
+		"denoms": ["gold"],
+		"plugin_options": [{"plugin": "names", "options": [{"key": "fee", "value": "5"}]}],
+		"options": [{"key": "faucet/period", "value": "10"}]
+	}`
+	genesis, err := ParseGenesis([]byte(object))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing genesis: %v", err)
+	}
+	if err := genesis.ValidateBasic(); err != nil {
+		t.Fatalf("Unexpected invalid genesis: %v", err)
+	}
+	expected := []KeyValue{
+		{"base/chainID", "test_chain_id"},
+		{"base/account", accJSON},
+		{"issue/reserve", "gold"},
+		{"names/fee", "5"},
+		{"faucet/period", "10"},
+	}
+	kvz := genesis.KeyValues()
+	if len(kvz) != len(expected) {
+		t.Fatalf("Expected %v options, got %v", expected, kvz)
+	}
+	for i, kv := range kvz {
+		if kv != expected[i] {
+			t.Errorf("Expected option %v to be %v, got %v", i, expected[i], kv)
+		}
+	}
+	if supply := genesis.TotalSupply(); !supply.IsEqual(acc.Balance) {
+		t.Errorf("Expected total supply %v, got %v", acc.Balance, supply)
+	}
+
+	legacy := `["base/chainID", "test_chain_id", "base/account", ` + accJSON + `]`
+	genesis, err = ParseGenesis([]byte(legacy))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing legacy genesis: %v", err)
+	}
+	if err := genesis.ValidateBasic(); err != nil {
+		t.Fatalf("Unexpected invalid legacy genesis: %v", err)
+	}
+	if len(genesis.Options) != 2 || genesis.Options[1].Key != "base/account" {
+		t.Errorf("Expected legacy entries as options, got %v", genesis.Options)
+	}
+	if _, err := ParseGenesis([]byte(`["base/chainID"]`)); err == nil {
+		t.Error("Expected a legacy genesis with an odd number of items to fail")
+	}
+}
+
+func TestGenesisValidateBasic(t *testing.T) {
+	acc := tests.PrivAccountFromSecret("genesis").Account
+	acc.Balance = types.Coins{{"", 100}}
+	accJSON := string(wire.JSONBytes(&acc))
+
+	noChainID := &Genesis{Options: []KeyValue{{"base/account", accJSON}}}
+	if err := noChainID.ValidateBasic(); err == nil {
+		t.Error("Expected a genesis without a chain ID to fail")
+	}
+
+	duplicate := &Genesis{
+		ChainID: "test_chain_id",
+		Options: []KeyValue{{"base/account", accJSON}, {"base/account", accJSON}},
+	}
+	if err := duplicate.ValidateBasic(); err == nil {
+		t.Error("Expected a duplicate account to fail")
+	}
+
+	// An exported account, as a raw store entry, at the same address.
+	entry := &StoreEntry{
+		Key:   sm.AccountKey(acc.PubKey.Address()),
+		Value: wire.BinaryBytes(&acc),
+	}
+	duplicateEntry := &Genesis{
+		ChainID: "test_chain_id",
+		Options: []KeyValue{{"base/store", string(wire.JSONBytes(entry))}, {"base/account", accJSON}},
+	}
+	if err := duplicateEntry.ValidateBasic(); err == nil {
+		t.Error("Expected an account duplicating a store entry account to fail")
+	}
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+
+	sm "github.com/tendermint/basecoin/state"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+)
+
+const snapshotVersion = 1
+
+// A Snapshot holds every key of the state at a committed height.
+type Snapshot struct {
+	Header  SnapshotHeader `json:"header"`
+	Entries []StoreEntry   `json:"entries"` // In index order
+}
+
+type SnapshotHeader struct {
+	Version  int    `json:"version"`
+	ChainID  string `json:"chain_id"`
+	Height   uint64 `json:"height"`
+	AppHash  []byte `json:"app_hash"`
+	Checksum []byte `json:"checksum"` // SHA256 of the binary encoded entries
+}
+
+func snapshotChecksum(entries []StoreEntry) []byte {
+	hash := sha256.Sum256(wire.BinaryBytes(entries))
+	return hash[:]
+}
+
+// Returns a snapshot of the state at the last commit. Fails in the middle
+// of a block, when the state has changes that aren't committed yet.
+func (app *Basecoin) Snapshot() (*Snapshot, error) {
+	if err := sm.CheckIndexComplete(app.state); err != nil {
+		return nil, err
+	}
+	if err := app.checkCommitBoundary(); err != nil {
+		return nil, err
+	}
+	if len(app.lastCommit.AppHash) == 0 {
+		return nil, errors.New("No app hash of the last commit, load the commit file first")
+	}
+	var entries []StoreEntry
+	sm.IterateKeys(app.state, func(key []byte, value []byte) bool {
+		entries = append(entries, StoreEntry{Key: key, Value: value})
+		return true
+	})
+	return &Snapshot{
+		Header: SnapshotHeader{
+			Version:  snapshotVersion,
+			ChainID:  app.state.GetChainID(),
+			Height:   app.lastCommit.Height,
+			AppHash:  app.lastCommit.AppHash,
+			Checksum: snapshotChecksum(entries),
+		},
+		Entries: entries,
+	}, nil
+}
+
+// Loads a snapshot into an empty store, commits it and checks that
+// the app hash matches the one in the snapshot header.
+func (app *Basecoin) Restore(snapshot *Snapshot) error {
+	header := snapshot.Header
+	if header.Version != snapshotVersion {
+		return errors.New(Fmt("Unsupported snapshot version %v", header.Version))
+	}
+	if !bytes.Equal(header.Checksum, snapshotChecksum(snapshot.Entries)) {
+		return errors.New("Snapshot checksum does not match its entries")
+	}
+	if sm.GetKeyCount(app.state) != 0 {
+		return errors.New("Cannot restore a snapshot into a store that has state")
+	}
+	if err := app.checkCommitBoundary(); err != nil {
+		return err
+	}
+
+	// Setting the keys in index order rebuilds the same tree.
+	for _, entry := range snapshot.Entries {
+		app.state.Set(entry.Key, entry.Value)
+	}
+	res := app.eyesCli.CommitSync()
+	if res.IsErr() {
+		return errors.New("Error getting hash: " + res.Error())
+	}
+	if !bytes.Equal(res.Data, header.AppHash) {
+		return errors.New(Fmt("Restored app hash %X does not match snapshot app hash %X",
+			res.Data, header.AppHash))
+	}
+	// Picks up the restored chain ID.
+	app.state = sm.NewState(sm.NewIndexedKVStore(app.eyesCli))
+	app.lastCommit = LastCommit{
+		Height:  header.Height,
+		AppHash: res.Data,
+	}
+	return nil
+}
+
+// Returns an error if a block was begun but not committed.
+func (app *Basecoin) checkCommitBoundary() error {
+	if height := app.state.GetBlockHeight(); height > app.lastCommit.Height {
+		return errors.New(Fmt("Block %v is not committed yet", height))
+	}
+	if height := sm.GetLastHeight(app.state); height != app.lastCommit.Height {
+		return errors.New(Fmt("The state is at height %v, but the last commit was at height %v",
+			height, app.lastCommit.Height))
+	}
+	return nil
+}
+
+func WriteSnapshot(filePath string, snapshot *Snapshot) error {
+	return WriteFileAtomic(filePath, wire.BinaryBytes(snapshot), 0644)
+}
+
+func ReadSnapshot(filePath string) (*Snapshot, error) {
+	data, err := ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot *Snapshot
+	if err := wire.ReadBinaryBytes(data, &snapshot); err != nil {
+		return nil, errors.New("Error decoding snapshot: " + err.Error())
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tendermint/basecoin/tests"
+	"github.com/tendermint/basecoin/types"
+	"github.com/tendermint/go-wire"
+	eyescli "github.com/tendermint/merkleeyes/client"
+)
+
+// Returns an app with one account, committed at height 1.
+func committedApp() *Basecoin {
+	bcApp := NewBasecoin(eyescli.NewLocalClient())
+	bcApp.SetOption("base/chainID", "test_chain_id")
+	acc := tests.PrivAccountFromSecret("snapshotter").Account
+	acc.Balance = types.Coins{{"", 100}}
+	bcApp.SetOption("base/account", string(wire.JSONBytes(&acc)))
+	bcApp.BeginBlock(1)
+	bcApp.EndBlock(1)
+	bcApp.Commit()
+	return bcApp
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	bcApp := committedApp()
+	snap, err := bcApp.Snapshot()
+	if err != nil {
+		t.Fatalf("Unexpected error taking snapshot: %v", err)
+	}
+	if snap.Header.Height != 1 || !bytes.Equal(snap.Header.AppHash, bcApp.LastCommit().AppHash) {
+		t.Fatalf("Expected the snapshot at the last commit, got %v", snap.Header)
+	}
+
+	restored := NewBasecoin(eyescli.NewLocalClient())
+	if err := restored.Restore(snap); err != nil {
+		t.Fatalf("Unexpected error restoring: %v", err)
+	}
+	if restored.LastCommit().Height != 1 {
+		t.Errorf("Expected the restored app at height 1, got %v", restored.LastCommit().Height)
+	}
+	if chainID := restored.state.GetChainID(); chainID != "test_chain_id" {
+		t.Errorf("Expected the restored chain ID, got %v", chainID)
+	}
+}
+
+func TestSnapshotMidBlock(t *testing.T) {
+	bcApp := committedApp()
+	bcApp.BeginBlock(2)
+	if _, err := bcApp.Snapshot(); err == nil {
+		t.Fatal("Expected a snapshot in the middle of a block to fail")
+	}
+}
+
+func TestRestoreMismatch(t *testing.T) {
+	bcApp := committedApp()
+	snap, err := bcApp.Snapshot()
+	if err != nil {
+		t.Fatalf("Unexpected error taking snapshot: %v", err)
+	}
+
+	tampered := *snap
+	tampered.Entries = append([]StoreEntry{}, snap.Entries...)
+	tampered.Entries[0].Value = []byte("tampered")
+	if err := NewBasecoin(eyescli.NewLocalClient()).Restore(&tampered); err == nil {
+		t.Error("Expected a snapshot whose entries don't match its checksum to fail")
+	}
+
+	wrongHash := *snap
+	wrongHash.Header.AppHash = []byte("wrong_app_hash")
+	if err := NewBasecoin(eyescli.NewLocalClient()).Restore(&wrongHash); err == nil {
+		t.Error("Expected a snapshot whose entries don't match its app hash to fail")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-rpc/client"
+	"github.com/tendermint/go-wire"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+// Sends tx to the node and waits for it to be committed.
+func broadcastTx(node string, tx types.Tx) (*ctypes.ResultBroadcastTx, error) {
+	txBytes := wire.BinaryBytes(struct{ types.Tx }{tx})
+	return broadcastTxBytes(node, txBytes)
+}
+
+func broadcastTxBytes(node string, txBytes []byte) (*ctypes.ResultBroadcastTx, error) {
+	var result ctypes.TMResult
+	_, err := rpcclient.NewClientJSONRPC(node).Call("broadcast_tx_commit", []interface{}{txBytes}, &result)
+	if err != nil {
+		return nil, errors.New("broadcasting tx: " + err.Error())
+	}
+	res, ok := result.(*ctypes.ResultBroadcastTx)
+	if !ok {
+		return nil, errors.New(Fmt("Unexpected broadcast result %v", result))
+	}
+	return res, nil
+}
+
+// Runs a TMSP query on the app behind the node.
+func queryApp(node string, query []byte) (tmsp.Result, error) {
+	var result ctypes.TMResult
+	_, err := rpcclient.NewClientJSONRPC(node).Call("tmsp_query", []interface{}{query}, &result)
+	if err != nil {
+		return tmsp.Result{}, errors.New("querying app: " + err.Error())
+	}
+	res, ok := result.(*ctypes.ResultTMSPQuery)
+	if !ok {
+		return tmsp.Result{}, errors.New(Fmt("Unexpected query result %v", result))
+	}
+	return res.Result, nil
+}
+
+// Returns the account at addr, or nil if there is none.
+func queryAccount(node string, addr []byte) (*types.AccountBalance, error) {
+	res, err := queryApp(node, append([]byte{0x01}, addr...))
+	if err != nil {
+		return nil, err
+	}
+	if res.Code == tmsp.CodeType_BaseUnknownAddress {
+		return nil, nil
+	}
+	if res.IsErr() {
+		return nil, errors.New("querying account: " + res.Error())
+	}
+	var balance *types.AccountBalance
+	if err := wire.ReadBinaryBytes(res.Data, &balance); err != nil {
+		return nil, errors.New("decoding account: " + err.Error())
+	}
+	return balance, nil
+}
+
+// Returns the sequence the next input from addr must have.
+func nextSequence(node string, addr []byte) (int, error) {
+	balance, err := queryAccount(node, addr)
+	if err != nil {
+		return 0, err
+	}
+	if balance == nil {
+		return 1, nil
+	}
+	return balance.Account.Sequence + 1, nil
+}
+
+// Builds an input from pubKey's address, with the pub key only on the first one.
+func newTxInput(pubKey crypto.PubKey, coins types.Coins, sequence int) types.TxInput {
+	input := types.TxInput{
+		Address:  pubKey.Address(),
+		Coins:    coins,
+		Sequence: sequence,
+	}
+	if sequence == 1 {
+		input.PubKey = pubKey
+	}
+	return input
+}
+
+//----------------------------------------
+
+// Parses coins like "10mycoin,5", where no denom is the default coin.
+func parseCoins(str string) (types.Coins, error) {
+	var coins types.Coins
+	if str == "" {
+		return coins, nil
+	}
+	for _, part := range strings.Split(str, ",") {
+		part = strings.TrimSpace(part)
+		i := 0
+		for i < len(part) && '0' <= part[i] && part[i] <= '9' {
+			i++
+		}
+		amount, err := strconv.ParseInt(part[:i], 10, 64)
+		if err != nil {
+			return nil, errors.New("Invalid coin amount in " + part)
+		}
+		coins = append(coins, types.Coin{Denom: part[i:], Amount: amount})
+	}
+	sort.Sort(coinsByDenom(coins))
+	if !coins.IsValid() {
+		return nil, errors.New("Invalid coins " + str)
+	}
+	return coins, nil
+}
+
+type coinsByDenom types.Coins
+
+func (c coinsByDenom) Len() int           { return len(c) }
+func (c coinsByDenom) Less(i, j int) bool { return c[i].Denom < c[j].Denom }
+func (c coinsByDenom) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+
+func parseAddress(str string) ([]byte, error) {
+	addr, err := hex.DecodeString(str)
+	if err != nil || len(addr) != 20 {
+		return nil, errors.New("Invalid address " + str)
+	}
+	return addr, nil
+}
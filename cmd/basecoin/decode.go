@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	"github.com/urfave/cli"
+)
+
+var decodeCmd = cli.Command{
+	Name:      "decode",
+	Usage:     "Print the canonical JSON of a tx from its hex bytes",
+	ArgsUsage: "[hex]",
+	Action:    cmdDecode,
+}
+
+func cmdDecode(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("decode takes the hex bytes of a tx")
+	}
+	txBytes, err := hex.DecodeString(strings.TrimPrefix(c.Args().First(), "0x"))
+	if err != nil {
+		return errors.New("Invalid hex: " + err.Error())
+	}
+	var tx types.Tx
+	if err := wire.ReadBinaryBytes(txBytes, &tx); err != nil {
+		return errors.New("decoding tx: " + err.Error())
+	}
+	txJSON, err := types.TxToJSON(tx)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(txJSON))
+	fmt.Println(Fmt("TxID: %X", types.TxID(c.GlobalString("chain_id"), tx)))
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	bc "github.com/tendermint/basecoin/app"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	"github.com/urfave/cli"
+)
+
+const initialBalance = 9007199254740992
+
+var initCmd = cli.Command{
+	Name:      "init",
+	Usage:     "Write a default genesis and config for a new chain",
+	ArgsUsage: "[dir]",
+	Action:    cmdInit,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "key",
+			Value: "genesis",
+			Usage: "Name of a new key to hold the genesis coins",
+		},
+		cli.StringFlag{
+			Name:  "plugins",
+			Usage: "Comma separated plugins to enable, or all if empty",
+		},
+	},
+}
+
+func cmdInit(c *cli.Context) error {
+	dir := "."
+	if c.NArg() > 0 {
+		dir = c.Args().First()
+	}
+	genesisPath := path.Join(dir, "genesis.json")
+	configPath := path.Join(dir, "config.json")
+	for _, filePath := range []string{genesisPath, configPath} {
+		if _, err := os.Stat(filePath); err == nil {
+			return errors.New(filePath + " already exists")
+		}
+	}
+
+	// The key of the genesis account.
+	passphrase, err := readNewPassphrase("Passphrase for the genesis key: ")
+	if err != nil {
+		return err
+	}
+	info, err := newKeystore(c).Create(c.String("key"), passphrase)
+	if err != nil {
+		return errors.New("creating key: " + err.Error())
+	}
+
+	acc := &types.Account{
+		PubKey:  info.PubKey,
+		Balance: types.Coins{{"", initialBalance}},
+	}
+	genesis := &bc.Genesis{
+		ChainID:  c.GlobalString("chain_id"),
+		Accounts: []json.RawMessage{wire.JSONBytes(acc)},
+	}
+	if plugins := c.String("plugins"); plugins != "" {
+		genesis.Plugins = strings.Split(plugins, ",")
+	}
+	genesisBytes, err := json.MarshalIndent(genesis, "", "  ")
+	if err != nil {
+		return errors.New("encoding genesis: " + err.Error())
+	}
+	if err := WriteFile(genesisPath, genesisBytes, 0644); err != nil {
+		return errors.New("writing genesis file: " + err.Error())
+	}
+
+	config := bc.DefaultConfig()
+	config.Plugins = genesis.Plugins
+	config.Genesis = genesisPath
+	config.CommitFile = path.Join(dir, "commit.json")
+	if err := bc.WriteConfig(configPath, config); err != nil {
+		return errors.New("writing config file: " + err.Error())
+	}
+
+	fmt.Println(Fmt("Initialized chain %v with key %v at %X", genesis.ChainID, info.Name, info.Address))
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/tendermint/basecoin/keystore"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var keysDirFlag = cli.StringFlag{
+	Name:  "keys",
+	Value: path.Join(os.Getenv("HOME"), ".basecoin", "keys"),
+	Usage: "Directory of the keystore",
+}
+
+var keysCmd = cli.Command{
+	Name:  "keys",
+	Usage: "Manage account keys",
+	Subcommands: []cli.Command{
+		{
+			Name:      "new",
+			Usage:     "Generate a new key",
+			ArgsUsage: "[name]",
+			Action:    cmdKeysNew,
+		},
+		{
+			Name:   "list",
+			Usage:  "List all keys",
+			Action: cmdKeysList,
+		},
+		{
+			Name:      "show",
+			Usage:     "Show the address and pub key of a key",
+			ArgsUsage: "[name]",
+			Action:    cmdKeysShow,
+		},
+		{
+			Name:      "export",
+			Usage:     "Write a key to a file, encrypted with a new passphrase",
+			ArgsUsage: "[name] [file]",
+			Action:    cmdKeysExport,
+		},
+		{
+			Name:      "import",
+			Usage:     "Add a key from a file written by export",
+			ArgsUsage: "[name] [file]",
+			Action:    cmdKeysImport,
+		},
+	},
+}
+
+// Shared, so buffered input isn't lost between reads.
+var stdin = bufio.NewReader(os.Stdin)
+
+func newKeystore(c *cli.Context) *keystore.Keystore {
+	return keystore.New(c.GlobalString("keys"))
+}
+
+// Reads a passphrase from the terminal without echoing it,
+// or a line from stdin if it isn't a terminal.
+func readPassphrase(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		line, err := stdin.ReadString('\n')
+		if err != nil {
+			return "", errors.New("reading passphrase: " + err.Error())
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", errors.New("reading passphrase: " + err.Error())
+	}
+	return string(passphrase), nil
+}
+
+// Reads a new passphrase twice, to catch typos.
+func readNewPassphrase(prompt string) (string, error) {
+	passphrase, err := readPassphrase(prompt)
+	if err != nil {
+		return "", err
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return passphrase, nil
+	}
+	repeated, err := readPassphrase("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != repeated {
+		return "", errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+func nameArg(c *cli.Context, numArgs int) (string, error) {
+	if c.NArg() != numArgs {
+		return "", errors.New(Fmt("%v takes %v", c.Command.Name, c.Command.ArgsUsage))
+	}
+	return c.Args().First(), nil
+}
+
+func cmdKeysNew(c *cli.Context) error {
+	name, err := nameArg(c, 1)
+	if err != nil {
+		return err
+	}
+	passphrase, err := readNewPassphrase("Passphrase for the new key: ")
+	if err != nil {
+		return err
+	}
+	info, err := newKeystore(c).Create(name, passphrase)
+	if err != nil {
+		return err
+	}
+	fmt.Println(Fmt("Created key %v with address %X", info.Name, info.Address))
+	return nil
+}
+
+func cmdKeysList(c *cli.Context) error {
+	infos, err := newKeystore(c).List()
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		fmt.Println(Fmt("%v\t%X", info.Name, info.Address))
+	}
+	return nil
+}
+
+func cmdKeysShow(c *cli.Context) error {
+	name, err := nameArg(c, 1)
+	if err != nil {
+		return err
+	}
+	info, err := newKeystore(c).Get(name)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(wire.JSONBytes(info)))
+	return nil
+}
+
+func cmdKeysExport(c *cli.Context) error {
+	name, err := nameArg(c, 2)
+	if err != nil {
+		return err
+	}
+	passphrase, err := readPassphrase("Passphrase of " + name + ": ")
+	if err != nil {
+		return err
+	}
+	exportPassphrase, err := readNewPassphrase("Passphrase for the exported key: ")
+	if err != nil {
+		return err
+	}
+	data, err := newKeystore(c).Export(name, passphrase, exportPassphrase)
+	if err != nil {
+		return err
+	}
+	return WriteFile(c.Args().Get(1), data, 0600)
+}
+
+func cmdKeysImport(c *cli.Context) error {
+	name, err := nameArg(c, 2)
+	if err != nil {
+		return err
+	}
+	data, err := ReadFile(c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	exportPassphrase, err := readPassphrase("Passphrase of the exported key: ")
+	if err != nil {
+		return err
+	}
+	passphrase, err := readNewPassphrase("Passphrase for " + name + ": ")
+	if err != nil {
+		return err
+	}
+	info, err := newKeystore(c).Import(name, passphrase, data, exportPassphrase)
+	if err != nil {
+		return err
+	}
+	fmt.Println(Fmt("Imported key %v with address %X", info.Name, info.Address))
+	return nil
+}
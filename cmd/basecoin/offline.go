@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-crypto"
+	"github.com/urfave/cli"
+)
+
+/*
+The offline workflow splits tx send and tx app into steps that
+can run on different machines, passing the tx along as JSON:
+
+  - tx build       Write an unsigned tx, needs no node or private key
+  - tx sign        Add the signature of one key, needs no node
+  - tx merge       Combine the signatures of several signed copies
+  - tx broadcast   Send a fully signed tx to a node
+*/
+
+var outFlag = cli.StringFlag{
+	Name:  "out",
+	Usage: "File to write the tx to, or stdout if empty",
+}
+
+// Flags shared by the build commands.
+var buildFlags = []cli.Flag{
+	outFlag,
+	cli.IntFlag{
+		Name:  "fee",
+		Usage: "Fee in the default coin",
+	},
+	cli.IntFlag{
+		Name:  "gas",
+		Usage: "Gas to pay for",
+	},
+}
+
+var buildTxCmd = cli.Command{
+	Name:  "build",
+	Usage: "Write an unsigned tx as JSON",
+	Subcommands: []cli.Command{
+		{
+			Name:   "send",
+			Usage:  "Build a SendTx",
+			Action: cmdBuildSendTx,
+			Flags: append([]cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "input",
+					Usage: "Input as <key name or hex address>:<coins>:<sequence>, may repeat",
+				},
+				cli.StringSliceFlag{
+					Name:  "output",
+					Usage: "Output as <hex address or @name>:<coins>, may repeat",
+				},
+			}, buildFlags...),
+		},
+		{
+			Name:   "app",
+			Usage:  "Build an AppTx",
+			Action: cmdBuildAppTx,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "input",
+					Usage: "Input as <key name or hex address>:<coins>:<sequence>",
+				},
+				cli.IntFlag{
+					Name:  "type",
+					Usage: "Type byte of the plugin",
+				},
+				cli.StringFlag{
+					Name:  "data",
+					Usage: "Hex data of the plugin tx",
+				},
+			}, buildFlags...),
+		},
+	},
+}
+
+var signTxCmd = cli.Command{
+	Name:      "sign",
+	Usage:     "Sign the input of one key in a tx file",
+	ArgsUsage: "[file]",
+	Action:    cmdSignTx,
+	Flags: []cli.Flag{
+		outFlag,
+		cli.StringFlag{
+			Name:  "from",
+			Usage: "Name of the signing key",
+		},
+	},
+}
+
+var mergeTxCmd = cli.Command{
+	Name:      "merge",
+	Usage:     "Combine the signatures of copies of the same tx",
+	ArgsUsage: "[file] [file...]",
+	Action:    cmdMergeTx,
+	Flags: []cli.Flag{
+		outFlag,
+	},
+}
+
+var broadcastTxCmd = cli.Command{
+	Name:      "broadcast",
+	Usage:     "Send a signed tx file to the node",
+	ArgsUsage: "[file]",
+	Action:    cmdBroadcastTx,
+}
+
+//----------------------------------------
+
+func readTxFile(filePath string) (types.Tx, error) {
+	data, err := ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := types.TxFromJSON(data)
+	if err != nil {
+		return nil, errors.New(Fmt("decoding tx in %v: %v", filePath, err.Error()))
+	}
+	return tx, nil
+}
+
+// Writes tx in its canonical JSON form.
+func writeTxFile(filePath string, tx types.Tx) error {
+	data, err := types.TxToJSON(tx)
+	if err != nil {
+		return err
+	}
+	if filePath == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	return WriteFile(filePath, data, 0644)
+}
+
+// Parses <key name or hex address>:<coins>:<sequence>.
+// A key name is needed for the first input of an account,
+// which has to carry the pub key.
+func parseTxInput(c *cli.Context, str string) (types.TxInput, error) {
+	parts := strings.Split(str, ":")
+	if len(parts) != 3 {
+		return types.TxInput{}, errors.New("Invalid input " + str)
+	}
+	coins, err := parseCoins(parts[1])
+	if err != nil {
+		return types.TxInput{}, err
+	}
+	sequence, err := strconv.Atoi(parts[2])
+	if err != nil || sequence <= 0 {
+		return types.TxInput{}, errors.New("Invalid sequence in input " + str)
+	}
+	if addr, err := parseAddress(parts[0]); err == nil {
+		if sequence == 1 {
+			return types.TxInput{}, errors.New("The first input of an account needs a key name: " + str)
+		}
+		return types.TxInput{Address: addr, Coins: coins, Sequence: sequence}, nil
+	}
+	info, err := newKeystore(c).Get(parts[0])
+	if err != nil {
+		return types.TxInput{}, err
+	}
+	return newTxInput(info.PubKey, coins, sequence), nil
+}
+
+// Parses <hex address or @name>:<coins>.
+func parseTxOutput(str string) (types.TxOutput, error) {
+	parts := strings.Split(str, ":")
+	if len(parts) != 2 {
+		return types.TxOutput{}, errors.New("Invalid output " + str)
+	}
+	coins, err := parseCoins(parts[1])
+	if err != nil {
+		return types.TxOutput{}, err
+	}
+	var addr []byte
+	if strings.HasPrefix(parts[0], "@") {
+		addr, err = outputAddress("", parts[0][1:])
+	} else {
+		addr, err = parseAddress(parts[0])
+	}
+	if err != nil {
+		return types.TxOutput{}, err
+	}
+	return types.TxOutput{Address: addr, Coins: coins}, nil
+}
+
+func cmdBuildSendTx(c *cli.Context) error {
+	tx := &types.SendTx{
+		Fee: int64(c.Int("fee")),
+		Gas: int64(c.Int("gas")),
+	}
+	for _, str := range c.StringSlice("input") {
+		input, err := parseTxInput(c, str)
+		if err != nil {
+			return err
+		}
+		tx.Inputs = append(tx.Inputs, input)
+	}
+	for _, str := range c.StringSlice("output") {
+		output, err := parseTxOutput(str)
+		if err != nil {
+			return err
+		}
+		tx.Outputs = append(tx.Outputs, output)
+	}
+	if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+		return errors.New("SendTx needs at least one input and one output")
+	}
+	return writeTxFile(c.String("out"), tx)
+}
+
+func cmdBuildAppTx(c *cli.Context) error {
+	input, err := parseTxInput(c, c.String("input"))
+	if err != nil {
+		return err
+	}
+	data, err := hex.DecodeString(c.String("data"))
+	if err != nil {
+		return errors.New("Invalid data: " + err.Error())
+	}
+	tx := &types.AppTx{
+		Fee:   int64(c.Int("fee")),
+		Gas:   int64(c.Int("gas")),
+		Type:  byte(c.Int("type")),
+		Input: input,
+		Data:  data,
+	}
+	return writeTxFile(c.String("out"), tx)
+}
+
+func cmdSignTx(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("sign takes a tx file")
+	}
+	tx, err := readTxFile(c.Args().First())
+	if err != nil {
+		return err
+	}
+	if err := signTx(c, tx); err != nil {
+		return err
+	}
+	return writeTxFile(c.String("out"), tx)
+}
+
+// Every file must hold the same tx, so it has the same sign bytes.
+// Each input takes the signature from whichever file has one.
+// Signatures are checked for inputs whose pub key is known, from the
+// tx itself or from the keystore, so a bad one is caught before broadcast.
+func cmdMergeTx(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return errors.New("merge takes two or more tx files")
+	}
+	chainID := c.GlobalString("chain_id")
+	pubKeys, err := keystorePubKeys(c)
+	if err != nil {
+		return err
+	}
+	var merged types.Tx
+	for _, filePath := range c.Args() {
+		tx, err := readTxFile(filePath)
+		if err != nil {
+			return err
+		}
+		// The first file is merged into itself, to check its signatures.
+		if merged == nil {
+			merged = tx
+		}
+		if !bytes.Equal(tx.SignBytes(chainID), merged.SignBytes(chainID)) {
+			return errors.New(filePath + " holds a different tx")
+		}
+		mergedInputs, inputs := txInputs(merged), txInputs(tx)
+		for i, input := range mergedInputs {
+			pubKey := input.PubKey
+			if pubKey == nil {
+				pubKey = pubKeys[string(input.Address)]
+			}
+			err := mergeSignature(input, inputs[i].Signature, pubKey, merged.SignBytes(chainID))
+			if err != nil {
+				return errors.New(Fmt("%v: %v", filePath, err.Error()))
+			}
+		}
+	}
+	return writeTxFile(c.String("out"), merged)
+}
+
+// Returns pointers to the inputs of tx, so they can be signed in place.
+func txInputs(tx types.Tx) []*types.TxInput {
+	switch tx := tx.(type) {
+	case *types.SendTx:
+		inputs := make([]*types.TxInput, len(tx.Inputs))
+		for i := range tx.Inputs {
+			inputs[i] = &tx.Inputs[i]
+		}
+		return inputs
+	case *types.AppTx:
+		return []*types.TxInput{&tx.Input}
+	}
+	return nil
+}
+
+// Sets sig on input, unless it conflicts with a signature already there.
+// With pubKey, which may be nil, sig must also sign signBytes.
+func mergeSignature(input *types.TxInput, sig crypto.Signature, pubKey crypto.PubKey, signBytes []byte) error {
+	if sig == nil {
+		return nil
+	}
+	if input.Signature != nil && !bytes.Equal(input.Signature.Bytes(), sig.Bytes()) {
+		return errors.New(Fmt("conflicting signatures for input %X", input.Address))
+	}
+	if pubKey != nil && !pubKey.VerifyBytes(signBytes, sig) {
+		return errors.New(Fmt("invalid signature for input %X", input.Address))
+	}
+	input.Signature = sig
+	return nil
+}
+
+// Returns the pub keys in the keystore by address.
+func keystorePubKeys(c *cli.Context) (map[string]crypto.PubKey, error) {
+	infos, err := newKeystore(c).List()
+	if err != nil {
+		return nil, err
+	}
+	pubKeys := make(map[string]crypto.PubKey)
+	for _, info := range infos {
+		pubKeys[string(info.Address)] = info.PubKey
+	}
+	return pubKeys, nil
+}
+
+func cmdBroadcastTx(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("broadcast takes a tx file")
+	}
+	tx, err := readTxFile(c.Args().First())
+	if err != nil {
+		return err
+	}
+	for _, input := range txInputs(tx) {
+		if input.Signature == nil {
+			return errors.New(Fmt("Input %X is not signed", input.Address))
+		}
+	}
+	return broadcastAndPrint(c, tx)
+}
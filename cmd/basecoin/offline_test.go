@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/tendermint/basecoin/keystore"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/urfave/cli"
+)
+
+// Runs the basecoin tx command with args for chainID,
+// reading passphrase from stdin.
+func runTxCmd(keysDir string, chainID string, passphrase string, args ...string) error {
+	stdin = bufio.NewReader(strings.NewReader(passphrase + "\n"))
+	app := cli.NewApp()
+	app.Flags = []cli.Flag{chainIDFlag, nodeFlag, keysDirFlag}
+	app.Commands = []cli.Command{txCmd}
+	return app.Run(append([]string{"basecoin", "--keys", keysDir, "--chain_id", chainID, "tx"}, args...))
+}
+
+func TestOfflineBuildSignMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "basecoin_offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keysDir := path.Join(dir, "keys")
+	ks := keystore.New(keysDir)
+	alice, err := ks.Create("alice", "alicepass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := ks.Create("bob", "bobpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := func(name string) string { return path.Join(dir, name) }
+
+	// Alice spends for the first time, bob has spent before.
+	err = runTxCmd(keysDir, "test_chain_id", "", "build", "send", "--out", file("unsigned.json"),
+		"--input", "alice:10:1", "--input", Fmt("%X:5:3", bob.Address),
+		"--output", Fmt("%X:15", []byte("carol_address_000000")))
+	if err != nil {
+		t.Fatalf("Unexpected error building: %v", err)
+	}
+	tx, err := readTxFile(file("unsigned.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := txInputs(tx)
+	if len(inputs) != 2 || inputs[0].PubKey == nil || inputs[1].PubKey != nil || inputs[0].Signature != nil {
+		t.Fatalf("Expected an unsigned tx with alice's pub key only, got %v", tx)
+	}
+
+	if err := runTxCmd(keysDir, "test_chain_id", "alicepass", "sign", "--from", "alice", "--out", file("alice.json"), file("unsigned.json")); err != nil {
+		t.Fatalf("Unexpected error signing: %v", err)
+	}
+	if err := runTxCmd(keysDir, "test_chain_id", "bobpass", "sign", "--from", "bob", "--out", file("bob.json"), file("unsigned.json")); err != nil {
+		t.Fatalf("Unexpected error signing: %v", err)
+	}
+	if err := runTxCmd(keysDir, "test_chain_id", "", "merge", "--out", file("merged.json"), file("alice.json"), file("bob.json")); err != nil {
+		t.Fatalf("Unexpected error merging: %v", err)
+	}
+	merged, err := readTxFile(file("merged.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signBytes := merged.SignBytes("test_chain_id")
+	for i, info := range []keystore.Info{alice, bob} {
+		sig := txInputs(merged)[i].Signature
+		if sig == nil || !info.PubKey.VerifyBytes(signBytes, sig) {
+			t.Errorf("Expected a valid signature from %v, got %v", info.Name, sig)
+		}
+	}
+
+	// A signature for another chain is caught, with the pub key from the
+	// tx for alice and from the keystore for bob.
+	for _, name := range []string{"alice", "bob"} {
+		wrongChain := file(name + "_wrong_chain.json")
+		err := runTxCmd(keysDir, "other_chain", name+"pass", "sign", "--from", name,
+			"--out", wrongChain, file("unsigned.json"))
+		if err != nil {
+			t.Fatalf("Unexpected error signing: %v", err)
+		}
+		if err := runTxCmd(keysDir, "test_chain_id", "", "merge", file("unsigned.json"), wrongChain); err == nil {
+			t.Errorf("Expected merging %v's signature for another chain to fail", name)
+		}
+	}
+
+	sendTx := tx.(*types.SendTx)
+	other := &types.SendTx{Inputs: sendTx.Inputs[:1], Outputs: sendTx.Outputs}
+	if err := writeTxFile(file("other.json"), other); err != nil {
+		t.Fatal(err)
+	}
+	if err := runTxCmd(keysDir, "test_chain_id", "", "merge", file("alice.json"), file("other.json")); err == nil {
+		t.Error("Expected merging a different tx to fail")
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tendermint/go-wire"
+	"github.com/urfave/cli"
+)
+
+var queryCmd = cli.Command{
+	Name:  "query",
+	Usage: "Query the state of the app",
+	Subcommands: []cli.Command{
+		{
+			Name:      "account",
+			Usage:     "Show the balance and sequence of an account",
+			ArgsUsage: "[address]",
+			Action:    cmdQueryAccount,
+		},
+	},
+}
+
+func cmdQueryAccount(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("query account takes an address")
+	}
+	addr, err := parseAddress(c.Args().First())
+	if err != nil {
+		return err
+	}
+	balance, err := queryAccount(c.GlobalString("node"), addr)
+	if err != nil {
+		return err
+	}
+	if balance == nil {
+		return errors.New("account not found")
+	}
+	fmt.Println(string(wire.JSONBytes(balance)))
+	return nil
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	bc "github.com/tendermint/basecoin/app"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmspcli "github.com/tendermint/tmsp/client"
+	tmsp "github.com/tendermint/tmsp/types"
+	"github.com/urfave/cli"
+)
+
+var restCmd = cli.Command{
+	Name:   "rest",
+	Usage:  "Serve accounts, plugin state and tx submission over HTTP and JSON",
+	Action: cmdRest,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "laddr",
+			Value: "127.0.0.1:8998",
+			Usage: "Address to serve HTTP on",
+		},
+		cli.StringFlag{
+			Name:  "app",
+			Value: "tcp://127.0.0.1:46658",
+			Usage: "TMSP address of the basecoin app, for reading state",
+		},
+	},
+}
+
+// Query type bytes of the plugins that answer queries.
+var restPlugins = map[string]byte{
+	bc.PluginNameEscrow:  bc.PluginTypeByteEscrow,
+	bc.PluginNameHTLC:    bc.PluginTypeByteHTLC,
+	bc.PluginNameIssue:   bc.PluginTypeByteIssue,
+	bc.PluginNameStake:   bc.PluginTypeByteStake,
+	bc.PluginNameNames:   bc.PluginTypeByteNames,
+	bc.PluginNamePaychan: bc.PluginTypeBytePaychan,
+	bc.PluginNameCoingov: bc.PluginTypeByteCoingov,
+}
+
+func cmdRest(c *cli.Context) error {
+	// The default chain ID would give wrong tx ids on any other chain.
+	if !c.GlobalIsSet("chain_id") {
+		return errors.New("--chain_id is required, to compute tx ids")
+	}
+	appCli, err := tmspcli.NewClient(c.String("app"), "socket", false)
+	if err != nil {
+		return errors.New("connecting to app: " + err.Error())
+	}
+	node := c.GlobalString("node")
+	rs := &restServer{
+		appCli: appCli,
+		broadcast: func(tx types.Tx) (*ctypes.ResultBroadcastTx, error) {
+			return broadcastTx(node, tx)
+		},
+		chainID: c.GlobalString("chain_id"),
+	}
+	return http.ListenAndServe(c.String("laddr"), rs.handler())
+}
+
+/*
+restServer reads state through a TMSP client to the app, and submits
+txs to the node's RPC, since only the node can put them into blocks.
+
+	GET  /accounts/<address>           Balance and sequence of an account
+	GET  /accounts/<address>/sequence  Sequence the next input must have
+	GET  /plugins/<name>/query?data=   A plugin query with hex data
+	POST /txs                          A signed tx in canonical JSON
+*/
+type restServer struct {
+	mtx       sync.Mutex // One query at a time on appCli
+	appCli    restAppClient
+	broadcast func(tx types.Tx) (*ctypes.ResultBroadcastTx, error)
+	chainID   string
+}
+
+// The part of the TMSP client that restServer uses.
+type restAppClient interface {
+	QuerySync(query []byte) tmsp.Result
+}
+
+func (rs *restServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/accounts/", rs.handleAccount)
+	mux.HandleFunc("/plugins/", rs.handlePluginQuery)
+	mux.HandleFunc("/txs", rs.handleTx)
+	return mux
+}
+
+type restError struct {
+	Error string `json:"error"`
+}
+
+type restSequence struct {
+	Address  string `json:"address"`
+	Sequence int    `json:"sequence"` // Of the last committed input
+	Next     int    `json:"next"`
+}
+
+// The result of a query, or of a committed tx.
+type restQueryResult struct {
+	Code tmsp.CodeType `json:"code"`
+	Data string        `json:"data"` // Hex
+	Log  string        `json:"log"`
+}
+
+type restTxResult struct {
+	TxID string `json:"tx_id"`
+	restQueryResult
+}
+
+func (rs *restServer) query(query []byte) tmsp.Result {
+	rs.mtx.Lock()
+	defer rs.mtx.Unlock()
+	return rs.appCli.QuerySync(query)
+}
+
+// Serves /accounts/<address> and /accounts/<address>/sequence.
+func (rs *restServer) handleAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeRestError(w, http.StatusMethodNotAllowed, "Use GET")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/accounts/"), "/")
+	if len(parts) > 2 || (len(parts) == 2 && parts[1] != "sequence") {
+		writeRestError(w, http.StatusNotFound, "Unknown path "+r.URL.Path)
+		return
+	}
+	addr, err := parseAddress(parts[0])
+	if err != nil {
+		writeRestError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	res := rs.query(append([]byte{bc.PluginTypeByteBase}, addr...))
+	var balance *types.AccountBalance
+	switch {
+	case res.Code == tmsp.CodeType_BaseUnknownAddress:
+		// No account yet
+	case res.IsErr():
+		writeRestError(w, http.StatusBadGateway, "Error querying account: "+res.Error())
+		return
+	default:
+		if err := wire.ReadBinaryBytes(res.Data, &balance); err != nil {
+			writeRestError(w, http.StatusBadGateway, "Error decoding account: "+err.Error())
+			return
+		}
+	}
+
+	if len(parts) == 2 {
+		seq := restSequence{Address: Fmt("%X", addr), Next: 1}
+		if balance != nil {
+			seq.Sequence = balance.Account.Sequence
+			seq.Next = balance.Account.Sequence + 1
+		}
+		writeRestJSON(w, seq)
+		return
+	}
+	if balance == nil {
+		writeRestError(w, http.StatusNotFound, Fmt("Account %X not found", addr))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(wire.JSONBytes(balance))
+}
+
+// Serves /plugins/<name>/query?data=<hex>.
+func (rs *restServer) handlePluginQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeRestError(w, http.StatusMethodNotAllowed, "Use GET")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/plugins/"), "/")
+	if len(parts) != 2 || parts[1] != "query" {
+		writeRestError(w, http.StatusNotFound, "Unknown path "+r.URL.Path)
+		return
+	}
+	typeByte, ok := restPlugins[parts[0]]
+	if !ok {
+		writeRestError(w, http.StatusNotFound, "Unknown plugin "+parts[0])
+		return
+	}
+	data, err := hex.DecodeString(r.URL.Query().Get("data"))
+	if err != nil {
+		writeRestError(w, http.StatusBadRequest, "Invalid hex data: "+err.Error())
+		return
+	}
+	res := rs.query(append([]byte{typeByte}, data...))
+	writeRestJSON(w, newRestQueryResult(res))
+}
+
+// Serves POST /txs, with a signed tx in canonical JSON as the body.
+func (rs *restServer) handleTx(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeRestError(w, http.StatusMethodNotAllowed, "Use POST")
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		writeRestError(w, http.StatusBadRequest, "Error reading body: "+err.Error())
+		return
+	}
+	tx, err := types.TxFromJSON(body)
+	if err != nil {
+		writeRestError(w, http.StatusBadRequest, "Error decoding tx: "+err.Error())
+		return
+	}
+	if res := validateTxBasic(tx); res.IsErr() {
+		writeRestError(w, http.StatusBadRequest, res.Error())
+		return
+	}
+	result, err := rs.broadcast(tx)
+	if err != nil {
+		writeRestError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeRestJSON(w, restTxResult{
+		TxID: Fmt("%X", types.TxID(rs.chainID, tx)),
+		restQueryResult: restQueryResult{
+			Code: result.Code,
+			Data: Fmt("%X", result.Data),
+			Log:  result.Log,
+		},
+	})
+}
+
+// Runs the checks ExecTx makes before looking at state, so malformed
+// txs are turned away without a round trip to the node.
+func validateTxBasic(tx types.Tx) tmsp.Result {
+	switch tx := tx.(type) {
+	case *types.SendTx:
+		if len(tx.Inputs) == 0 {
+			return tmsp.ErrBaseInvalidInput.AppendLog("Tx must have inputs")
+		}
+		for _, in := range tx.Inputs {
+			if res := validateSignedInput(in); res.IsErr() {
+				return res
+			}
+		}
+		if len(tx.Outputs) == 0 {
+			return tmsp.ErrBaseInvalidOutput.AppendLog("Tx must have outputs")
+		}
+		for _, out := range tx.Outputs {
+			if res := out.ValidateBasic(); res.IsErr() {
+				return res
+			}
+		}
+		return validateFee(tx.Fee)
+	case *types.AppTx:
+		if res := validateSignedInput(tx.Input); res.IsErr() {
+			return res
+		}
+		return validateFee(tx.Fee)
+	}
+	return tmsp.ErrBaseEncodingError.AppendLog("Unknown tx type")
+}
+
+func validateSignedInput(in types.TxInput) tmsp.Result {
+	if res := in.ValidateBasic(); res.IsErr() {
+		return res
+	}
+	if in.Signature == nil {
+		return tmsp.ErrBaseInvalidInput.AppendLog(Fmt("Input %X is not signed", in.Address))
+	}
+	return tmsp.OK
+}
+
+func validateFee(fee int64) tmsp.Result {
+	if fee < 0 {
+		return tmsp.ErrBaseInvalidInput.AppendLog("Fee cannot be negative")
+	}
+	return tmsp.OK
+}
+
+//----------------------------------------
+
+func newRestQueryResult(res tmsp.Result) restQueryResult {
+	return restQueryResult{
+		Code: res.Code,
+		Data: Fmt("%X", res.Data),
+		Log:  res.Log,
+	}
+}
+
+func writeRestJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		writeRestError(w, http.StatusInternalServerError, "Error encoding response: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func writeRestError(w http.ResponseWriter, status int, message string) {
+	data, _ := json.Marshal(restError{message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
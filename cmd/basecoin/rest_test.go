@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	bc "github.com/tendermint/basecoin/app"
+	"github.com/tendermint/basecoin/tests"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+// An app that answers queries from a map, and unknown account otherwise.
+type fakeApp map[string]tmsp.Result
+
+func (fa fakeApp) QuerySync(query []byte) tmsp.Result {
+	if res, ok := fa[string(query)]; ok {
+		return res
+	}
+	return tmsp.ErrBaseUnknownAddress
+}
+
+// Returns a server over app that records the txs it broadcasts.
+func newTestRestServer(app fakeApp) (*httptest.Server, *[]types.Tx) {
+	var broadcast []types.Tx
+	rs := &restServer{
+		appCli: app,
+		broadcast: func(tx types.Tx) (*ctypes.ResultBroadcastTx, error) {
+			broadcast = append(broadcast, tx)
+			return &ctypes.ResultBroadcastTx{Code: tmsp.CodeType_OK, Data: []byte{0x01}}, nil
+		},
+		chainID: "test_chain_id",
+	}
+	return httptest.NewServer(rs.handler()), &broadcast
+}
+
+// Sends the request and decodes the JSON response into v, if not nil.
+func doRest(t *testing.T, method, url, body string, v interface{}) int {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("Error decoding response to %v %v: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestRestAccount(t *testing.T) {
+	acc := tests.PrivAccountFromSecret("rest").Account
+	acc.Sequence = 4
+	acc.Balance = types.Coins{{"", 100}}
+	addr := acc.PubKey.Address()
+	balance := &types.AccountBalance{Account: &acc, Spendable: acc.Balance}
+	server, _ := newTestRestServer(fakeApp{
+		string(append([]byte{bc.PluginTypeByteBase}, addr...)): tmsp.NewResultOK(wire.BinaryBytes(balance), ""),
+	})
+	defer server.Close()
+
+	var got map[string]interface{}
+	if code := doRest(t, "GET", server.URL+Fmt("/accounts/%X", addr), "", &got); code != http.StatusOK {
+		t.Fatalf("Expected the account, got status %v", code)
+	}
+	if _, ok := got["account"]; !ok {
+		t.Errorf("Expected an account in the response, got %v", got)
+	}
+
+	var seq restSequence
+	doRest(t, "GET", server.URL+Fmt("/accounts/%X/sequence", addr), "", &seq)
+	if seq.Sequence != 4 || seq.Next != 5 {
+		t.Errorf("Expected sequence 4 and next 5, got %v", seq)
+	}
+	other := []byte("unknown_address_0000")
+	doRest(t, "GET", server.URL+Fmt("/accounts/%X/sequence", other), "", &seq)
+	if seq.Sequence != 0 || seq.Next != 1 {
+		t.Errorf("Expected a new account to start at 1, got %v", seq)
+	}
+
+	var restErr restError
+	cases := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"GET", Fmt("/accounts/%X", other), http.StatusNotFound},
+		{"GET", "/accounts/not_hex", http.StatusBadRequest},
+		{"GET", Fmt("/accounts/%X/balance", addr), http.StatusNotFound},
+		{"POST", Fmt("/accounts/%X", addr), http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		if code := doRest(t, c.method, server.URL+c.path, "", &restErr); code != c.status || restErr.Error == "" {
+			t.Errorf("Expected status %v and an error for %v %v, got %v %v", c.status, c.method, c.path, code, restErr)
+		}
+	}
+}
+
+func TestRestPluginQuery(t *testing.T) {
+	server, _ := newTestRestServer(fakeApp{
+		string([]byte{bc.PluginTypeByteEscrow, 0xAB}): tmsp.NewResultOK([]byte{0xCD}, "found"),
+	})
+	defer server.Close()
+
+	var res restQueryResult
+	if code := doRest(t, "GET", server.URL+"/plugins/escrow/query?data=AB", "", &res); code != http.StatusOK {
+		t.Fatalf("Expected the query result, got status %v", code)
+	}
+	if res.Code != tmsp.CodeType_OK || res.Data != "CD" || res.Log != "found" {
+		t.Errorf("Expected the escrow result, got %v", res)
+	}
+
+	var restErr restError
+	if code := doRest(t, "GET", server.URL+"/plugins/escrow/query?data=zz", "", &restErr); code != http.StatusBadRequest {
+		t.Errorf("Expected bad hex to be refused, got status %v", code)
+	}
+	if code := doRest(t, "GET", server.URL+"/plugins/nonexistent/query", "", &restErr); code != http.StatusNotFound {
+		t.Errorf("Expected an unknown plugin to be refused, got status %v", code)
+	}
+}
+
+func TestRestTx(t *testing.T) {
+	server, broadcast := newTestRestServer(fakeApp{})
+	defer server.Close()
+
+	privAcc := tests.PrivAccountFromSecret("rest")
+	tx := &types.SendTx{
+		Inputs: []types.TxInput{{
+			Address:  privAcc.Account.PubKey.Address(),
+			Coins:    types.Coins{{"", 10}},
+			Sequence: 1,
+			PubKey:   privAcc.Account.PubKey,
+		}},
+		Outputs: []types.TxOutput{{Address: []byte("bob_address_00000000"), Coins: types.Coins{{"", 10}}}},
+	}
+	unsigned, err := types.TxToJSON(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Inputs[0].Signature = privAcc.PrivKey.Sign(tx.SignBytes("test_chain_id"))
+	signed, err := types.TxToJSON(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var restErr restError
+	cases := []struct {
+		method string
+		body   string
+		status int
+	}{
+		{"GET", "", http.StatusMethodNotAllowed},
+		{"POST", "not json", http.StatusBadRequest},
+		{"POST", string(unsigned), http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if code := doRest(t, c.method, server.URL+"/txs", c.body, &restErr); code != c.status {
+			t.Errorf("Expected status %v for %v %q, got %v %v", c.status, c.method, c.body, code, restErr)
+		}
+	}
+	if len(*broadcast) != 0 {
+		t.Fatalf("Expected invalid txs not to be broadcast, got %v", *broadcast)
+	}
+
+	var res restTxResult
+	if code := doRest(t, "POST", server.URL+"/txs", string(signed), &res); code != http.StatusOK {
+		t.Fatalf("Expected the tx to be broadcast, got status %v", code)
+	}
+	if len(*broadcast) != 1 {
+		t.Fatalf("Expected one tx broadcast, got %v", *broadcast)
+	}
+	if expected := Fmt("%X", types.TxID("test_chain_id", tx)); res.TxID != expected || res.Data != "01" {
+		t.Errorf("Expected tx id %v and data 01, got %v", expected, res)
+	}
+}
+
+func TestValidateTxBasic(t *testing.T) {
+	privAcc := tests.PrivAccountFromSecret("rest")
+	input := types.TxInput{
+		Address:  privAcc.Account.PubKey.Address(),
+		Coins:    types.Coins{{"", 10}},
+		Sequence: 1,
+		PubKey:   privAcc.Account.PubKey,
+	}
+	signedInput := input
+	signedInput.Signature = privAcc.PrivKey.Sign([]byte("anything"))
+	output := types.TxOutput{Address: []byte("bob_address_00000000"), Coins: types.Coins{{"", 10}}}
+
+	cases := []struct {
+		tx    types.Tx
+		valid bool
+	}{
+		{&types.SendTx{Inputs: []types.TxInput{signedInput}, Outputs: []types.TxOutput{output}}, true},
+		{&types.SendTx{Outputs: []types.TxOutput{output}}, false},
+		{&types.SendTx{Inputs: []types.TxInput{signedInput}}, false},
+		{&types.SendTx{Inputs: []types.TxInput{input}, Outputs: []types.TxOutput{output}}, false},
+		{&types.SendTx{Inputs: []types.TxInput{signedInput}, Outputs: []types.TxOutput{output}, Fee: -1}, false},
+		{&types.AppTx{Input: signedInput}, true},
+		{&types.AppTx{Input: input}, false},
+	}
+	for i, c := range cases {
+		if res := validateTxBasic(c.tx); res.IsOK() != c.valid {
+			t.Errorf("Case %v: expected valid %v, got %v", i, c.valid, res)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	bc "github.com/tendermint/basecoin/app"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-logger"
+	eyes "github.com/tendermint/merkleeyes/client"
+	"github.com/tendermint/tmsp/server"
+	"github.com/urfave/cli"
+)
+
+var startCmd = cli.Command{
+	Name:   "start",
+	Usage:  "Start the basecoin TMSP app",
+	Action: cmdStart,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "config",
+			Value: "config.json",
+			Usage: "Config file, if any, overridden by BASECOIN_ variables and the other flags",
+		},
+		cli.StringFlag{
+			Name:  "address",
+			Usage: "Listen address",
+		},
+		cli.StringFlag{
+			Name:  "eyes",
+			Usage: "MerkleEyes address, or 'local' for embedded",
+		},
+		cli.StringFlag{
+			Name:  "genesis",
+			Usage: "Genesis file, if any",
+		},
+		cli.StringFlag{
+			Name:  "commit",
+			Usage: "File recording the last commit's height and app hash",
+		},
+		cli.StringFlag{
+			Name:  "max_tx_size",
+			Usage: "Largest tx accepted, in bytes",
+		},
+		cli.StringFlag{
+			Name:  "min_fee",
+			Usage: "Smallest fee accepted into the mempool",
+		},
+		cli.StringFlag{
+			Name:  "plugins",
+			Usage: "Comma separated plugins the chain must enable, like escrow,htlc",
+		},
+		cli.StringFlag{
+			Name:  "log_level",
+			Usage: "One of debug, info, notice, warn, error or crit",
+		},
+		cli.StringFlag{
+			Name:  "invariant_check_period",
+			Usage: "Blocks between checks that accounts add up to the supply, or 0 for none",
+		},
+		cli.StringFlag{
+			Name:  "metrics",
+			Usage: "Address to serve Prometheus metrics on at /metrics, like 127.0.0.1:46659",
+		},
+	},
+}
+
+// Loads the config from its file, then the environment, then the flags.
+func loadConfig(c *cli.Context) (*bc.Config, error) {
+	config, err := bc.LoadConfig(c.String("config"))
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	for _, flag := range []string{"address", "eyes", "genesis", "commit",
+		"max_tx_size", "min_fee", "plugins", "log_level", "metrics",
+		"invariant_check_period"} {
+		if !c.IsSet(flag) {
+			continue
+		}
+		key := flag
+		if flag == "commit" {
+			key = "commit_file"
+		}
+		if err := config.Set(key, c.String(flag)); err != nil {
+			return nil, errors.New("--" + flag + ": " + err.Error())
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func cmdStart(c *cli.Context) error {
+	config, err := loadConfig(c)
+	if err != nil {
+		return errors.New("loading config: " + err.Error())
+	}
+	logger.SetLogLevel(config.LogLevel)
+
+	// Connect to MerkleEyes
+	eyesCli, err := eyes.NewClient(config.Eyes, "socket")
+	if err != nil {
+		return errors.New("connect to MerkleEyes: " + err.Error())
+	}
+
+	// Create Basecoin app
+	app, err := bc.NewBasecoinWithConfig(eyesCli, config)
+	if err != nil {
+		return errors.New("creating app: " + err.Error())
+	}
+
+	// Make sure MerkleEyes is at the last recorded commit
+	if err := app.LoadLastCommit(config.CommitFile); err != nil {
+		return errors.New("last commit: " + err.Error())
+	}
+
+	// If genesis file was specified, set key-value options.
+	// Genesis was already applied if a block was committed.
+	if config.Genesis != "" && app.LastCommit().Height == 0 {
+		genesis, err := bc.LoadGenesis(config.Genesis)
+		if err != nil {
+			return errors.New("loading genesis file: " + err.Error())
+		}
+		if err := app.SetGenesis(genesis); err != nil {
+			return errors.New("setting genesis: " + err.Error())
+		}
+		fmt.Println(Fmt("Set genesis with total supply %v", genesis.TotalSupply()))
+	}
+
+	// Make sure the chain enables the plugins the config expects
+	if err := app.CheckEnabledPlugins(); err != nil {
+		return errors.New("plugins: " + err.Error())
+	}
+
+	// Serve metrics, if asked to
+	if config.Metrics != "" {
+		if err := serveMetrics(config.Metrics, app); err != nil {
+			return errors.New("serving metrics: " + err.Error())
+		}
+	}
+
+	// Start the listener
+	svr, err := server.NewServer(config.Address, "socket", app)
+	if err != nil {
+		return errors.New("create listener: " + err.Error())
+	}
+
+	// Wait forever
+	TrapSignal(func() {
+		// Cleanup
+		svr.Stop()
+	})
+	return nil
+}
+
+// Listens on addr before returning, so a bad address fails the start.
+func serveMetrics(addr string, app *bc.Basecoin) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", app.Metrics())
+	go http.Serve(listener, mux)
+	return nil
+}
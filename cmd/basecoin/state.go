@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	bc "github.com/tendermint/basecoin/app"
+	. "github.com/tendermint/go-common"
+	eyes "github.com/tendermint/merkleeyes/client"
+	"github.com/urfave/cli"
+)
+
+// An embedded MerkleEyes would be empty, so the address is required.
+var eyesFlag = cli.StringFlag{
+	Name:  "eyes",
+	Usage: "MerkleEyes address of the stopped node",
+}
+
+var exportCmd = cli.Command{
+	Name:   "export",
+	Usage:  "Write the state in MerkleEyes out as a genesis file",
+	Action: cmdExport,
+	Flags: []cli.Flag{
+		eyesFlag,
+		cli.StringFlag{
+			Name:  "out",
+			Value: "genesis.json",
+			Usage: "Genesis file to write",
+		},
+	},
+}
+
+var snapshotCmd = cli.Command{
+	Name:   "snapshot",
+	Usage:  "Write the last committed state in MerkleEyes to a snapshot file",
+	Action: cmdSnapshot,
+	Flags: []cli.Flag{
+		eyesFlag,
+		cli.StringFlag{
+			Name:  "commit",
+			Usage: "File recording the last commit of the node",
+		},
+		cli.StringFlag{
+			Name:  "out",
+			Value: "snapshot.bin",
+			Usage: "Snapshot file to write",
+		},
+	},
+}
+
+var restoreCmd = cli.Command{
+	Name:   "restore",
+	Usage:  "Load a snapshot file into an empty MerkleEyes",
+	Action: cmdRestore,
+	Flags: []cli.Flag{
+		eyesFlag,
+		cli.StringFlag{
+			Name:  "in",
+			Value: "snapshot.bin",
+			Usage: "Snapshot file to read",
+		},
+	},
+}
+
+func newApp(eyesAddr string) (*bc.Basecoin, error) {
+	if eyesAddr == "" || eyesAddr == "local" {
+		return nil, errors.New("--eyes must be the address of a MerkleEyes server")
+	}
+	eyesCli, err := eyes.NewClient(eyesAddr, "socket")
+	if err != nil {
+		return nil, errors.New("connect to MerkleEyes: " + err.Error())
+	}
+	return bc.NewBasecoin(eyesCli), nil
+}
+
+func cmdExport(c *cli.Context) error {
+	app, err := newApp(c.String("eyes"))
+	if err != nil {
+		return err
+	}
+	genesis, err := app.Export()
+	if err != nil {
+		return errors.New("exporting state: " + err.Error())
+	}
+	genesisBytes, err := json.MarshalIndent(genesis, "", "  ")
+	if err != nil {
+		return errors.New("encoding genesis: " + err.Error())
+	}
+	if err := WriteFile(c.String("out"), genesisBytes, 0644); err != nil {
+		return errors.New("writing genesis file: " + err.Error())
+	}
+	fmt.Println(Fmt("Exported %v keys with total supply %v", len(genesis.Options), genesis.TotalSupply()))
+	return nil
+}
+
+func cmdSnapshot(c *cli.Context) error {
+	app, err := newApp(c.String("eyes"))
+	if err != nil {
+		return err
+	}
+	if c.String("commit") == "" {
+		return errors.New("--commit is needed for the app hash of the last commit")
+	}
+	if err := app.ReadLastCommit(c.String("commit")); err != nil {
+		return errors.New("last commit: " + err.Error())
+	}
+	snap, err := app.Snapshot()
+	if err != nil {
+		return errors.New("taking snapshot: " + err.Error())
+	}
+	if err := bc.WriteSnapshot(c.String("out"), snap); err != nil {
+		return errors.New("writing snapshot file: " + err.Error())
+	}
+	fmt.Println(Fmt("Wrote snapshot of %v keys at height %v with app hash %X",
+		len(snap.Entries), snap.Header.Height, snap.Header.AppHash))
+	return nil
+}
+
+func cmdRestore(c *cli.Context) error {
+	snap, err := bc.ReadSnapshot(c.String("in"))
+	if err != nil {
+		return errors.New("reading snapshot file: " + err.Error())
+	}
+	app, err := newApp(c.String("eyes"))
+	if err != nil {
+		return err
+	}
+	if err := app.Restore(snap); err != nil {
+		return errors.New("restoring snapshot: " + err.Error())
+	}
+	fmt.Println(Fmt("Restored height %v with app hash %X", snap.Header.Height, snap.Header.AppHash))
+	return nil
+}
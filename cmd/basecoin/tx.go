@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/tendermint/basecoin/plugins/names"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/urfave/cli"
+)
+
+// Flags shared by the tx commands.
+var txFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "from",
+		Usage: "Name of the sender's key",
+	},
+	cli.StringFlag{
+		Name:  "amount",
+		Usage: "Coins to send, like 10mycoin,5",
+	},
+	cli.IntFlag{
+		Name:  "fee",
+		Usage: "Fee in the default coin",
+	},
+	cli.IntFlag{
+		Name:  "gas",
+		Usage: "Gas to pay for",
+	},
+	cli.IntFlag{
+		Name:  "sequence",
+		Usage: "Sequence of the input, or 0 to look it up",
+	},
+}
+
+var txCmd = cli.Command{
+	Name:  "tx",
+	Usage: "Sign and broadcast a transaction",
+	Subcommands: []cli.Command{
+		{
+			Name:   "send",
+			Usage:  "Send coins to an address or name",
+			Action: cmdSendTx,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "to",
+					Usage: "Hex address of the recipient",
+				},
+				cli.StringFlag{
+					Name:  "to_name",
+					Usage: "Registered name of the recipient, instead of an address",
+				},
+			}, txFlags...),
+		},
+		{
+			Name:   "app",
+			Usage:  "Send a tx to a plugin",
+			Action: cmdAppTx,
+			Flags: append([]cli.Flag{
+				cli.IntFlag{
+					Name:  "type",
+					Usage: "Type byte of the plugin",
+				},
+				cli.StringFlag{
+					Name:  "data",
+					Usage: "Hex data of the plugin tx",
+				},
+			}, txFlags...),
+		},
+		buildTxCmd,
+		signTxCmd,
+		mergeTxCmd,
+		broadcastTxCmd,
+	},
+}
+
+// Builds the input of the sender's key, common to every tx.
+func txInput(c *cli.Context) (types.TxInput, error) {
+	info, err := newKeystore(c).Get(c.String("from"))
+	if err != nil {
+		return types.TxInput{}, err
+	}
+	coins, err := parseCoins(c.String("amount"))
+	if err != nil {
+		return types.TxInput{}, err
+	}
+	fee := int64(c.Int("fee"))
+	if fee != 0 {
+		// The fee is paid out of the input, on top of what gets sent.
+		coins = coins.Plus(types.Coins{{"", fee}})
+	}
+	sequence := c.Int("sequence")
+	if sequence == 0 {
+		sequence, err = nextSequence(c.GlobalString("node"), info.Address)
+		if err != nil {
+			return types.TxInput{}, err
+		}
+	}
+	return newTxInput(info.PubKey, coins, sequence), nil
+}
+
+// Signs tx with the sender's key.
+func signTx(c *cli.Context, tx types.Tx) error {
+	name := c.String("from")
+	passphrase, err := readPassphrase("Passphrase of " + name + ": ")
+	if err != nil {
+		return err
+	}
+	return newKeystore(c).SignTx(name, passphrase, c.GlobalString("chain_id"), tx)
+}
+
+func cmdSendTx(c *cli.Context) error {
+	input, err := txInput(c)
+	if err != nil {
+		return err
+	}
+	addr, err := outputAddress(c.String("to"), c.String("to_name"))
+	if err != nil {
+		return err
+	}
+	output := types.TxOutput{Address: addr}
+	output.Coins, _ = parseCoins(c.String("amount"))
+	tx := &types.SendTx{
+		Fee:     int64(c.Int("fee")),
+		Gas:     int64(c.Int("gas")),
+		Inputs:  []types.TxInput{input},
+		Outputs: []types.TxOutput{output},
+	}
+	if err := signTx(c, tx); err != nil {
+		return err
+	}
+	return broadcastAndPrint(c, tx)
+}
+
+// Returns the hex address to, or the reference to the registered name toName.
+func outputAddress(to string, toName string) ([]byte, error) {
+	if toName == "" {
+		return parseAddress(to)
+	}
+	if to != "" {
+		return nil, errors.New("Give either an address or a name, not both")
+	}
+	if !names.IsValidName(toName) {
+		return nil, errors.New("Invalid name " + toName)
+	}
+	return types.NameRef(toName), nil
+}
+
+func cmdAppTx(c *cli.Context) error {
+	data, err := hex.DecodeString(c.String("data"))
+	if err != nil {
+		return errors.New("Invalid data: " + err.Error())
+	}
+	input, err := txInput(c)
+	if err != nil {
+		return err
+	}
+	tx := &types.AppTx{
+		Fee:   int64(c.Int("fee")),
+		Gas:   int64(c.Int("gas")),
+		Type:  byte(c.Int("type")),
+		Input: input,
+		Data:  data,
+	}
+	if err := signTx(c, tx); err != nil {
+		return err
+	}
+	return broadcastAndPrint(c, tx)
+}
+
+func broadcastAndPrint(c *cli.Context, tx types.Tx) error {
+	res, err := broadcastTx(c.GlobalString("node"), tx)
+	if err != nil {
+		return err
+	}
+	if res.Code != 0 {
+		return errors.New(Fmt("Tx failed with code %v: %v", res.Code, res.Log))
+	}
+	fmt.Println(Fmt("Committed tx %X", types.TxID(c.GlobalString("chain_id"), tx)))
+	return nil
+}
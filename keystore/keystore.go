@@ -0,0 +1,287 @@
+package keystore
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	maxNameLength = 64
+	fileExt       = ".json"
+
+	// scrypt parameters for deriving the secretbox key from a passphrase.
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	saltLength   = 16
+	nonceLength  = 24
+	secretLength = 32
+)
+
+var ErrWrongPassphrase = errors.New("Wrong passphrase")
+
+// Info is the public part of a stored key.
+type Info struct {
+	Name    string        `json:"name"`
+	Address []byte        `json:"address"`
+	PubKey  crypto.PubKey `json:"pub_key"`
+}
+
+// An encrypted key, as stored on disk and exported.
+type keyFile struct {
+	Info       Info   `json:"info"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"` // The binary encoded PrivKey
+}
+
+// Keystore keeps Ed25519 keys in a directory, one file per name,
+// each encrypted with its own passphrase.
+type Keystore struct {
+	dir string
+}
+
+func New(dir string) *Keystore {
+	return &Keystore{
+		dir: dir,
+	}
+}
+
+func (ks *Keystore) keyPath(name string) string {
+	return path.Join(ks.dir, name+fileExt)
+}
+
+// Generates a new key under name.
+func (ks *Keystore) Create(name string, passphrase string) (Info, error) {
+	return ks.store(name, passphrase, crypto.GenPrivKeyEd25519())
+}
+
+// Returns the public part of the key under name.
+func (ks *Keystore) Get(name string) (Info, error) {
+	kf, err := ks.load(name)
+	if err != nil {
+		return Info{}, err
+	}
+	return kf.Info, nil
+}
+
+// Returns every key, sorted by name.
+func (ks *Keystore) List() ([]Info, error) {
+	files, err := ioutil.ReadDir(ks.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var infos []Info
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileExt) {
+			continue
+		}
+		info, err := ks.Get(strings.TrimSuffix(file.Name(), fileExt))
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	sort.Sort(infosByName(infos))
+	return infos, nil
+}
+
+// Removes the key under name, once passphrase shows the caller owns it.
+func (ks *Keystore) Delete(name string, passphrase string) error {
+	if _, err := ks.unlock(name, passphrase); err != nil {
+		return err
+	}
+	return os.Remove(ks.keyPath(name))
+}
+
+// Returns the key under name, encrypted with exportPassphrase
+// instead of its own, for Import() into another keystore.
+func (ks *Keystore) Export(name string, passphrase string, exportPassphrase string) ([]byte, error) {
+	privKey, err := ks.unlock(name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	kf, err := encrypt(name, exportPassphrase, privKey)
+	if err != nil {
+		return nil, err
+	}
+	return wire.JSONBytes(kf), nil
+}
+
+// Stores a key from Export() under name, encrypted with passphrase.
+func (ks *Keystore) Import(name string, passphrase string, data []byte, exportPassphrase string) (Info, error) {
+	var err error
+	var kf *keyFile
+	wire.ReadJSONPtr(&kf, data, &err)
+	if err != nil {
+		return Info{}, errors.New("Error decoding exported key: " + err.Error())
+	}
+	privKey, err := decrypt(kf, exportPassphrase)
+	if err != nil {
+		return Info{}, err
+	}
+	return ks.store(name, passphrase, privKey)
+}
+
+// Signs msg with the key under name.
+func (ks *Keystore) Sign(name string, passphrase string, msg []byte) (crypto.Signature, error) {
+	privKey, err := ks.unlock(name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return privKey.Sign(msg), nil
+}
+
+// Signs tx for chainID with the key under name, and sets the
+// signature on the input that spends from the key's address.
+func (ks *Keystore) SignTx(name string, passphrase string, chainID string, tx types.Tx) error {
+	privKey, err := ks.unlock(name, passphrase)
+	if err != nil {
+		return err
+	}
+	addr := privKey.PubKey().Address()
+	sig := privKey.Sign(tx.SignBytes(chainID))
+	switch tx := tx.(type) {
+	case *types.SendTx:
+		if !tx.SetSignature(addr, sig) {
+			return errors.New(Fmt("Tx has no input from %X", addr))
+		}
+	case *types.AppTx:
+		if !bytes.Equal(tx.Input.Address, addr) {
+			return errors.New(Fmt("Tx input is from %X, not %X", tx.Input.Address, addr))
+		}
+		tx.SetSignature(sig)
+	default:
+		return errors.New(Fmt("Cannot sign tx of type %T", tx))
+	}
+	return nil
+}
+
+//----------------------------------------
+
+func (ks *Keystore) store(name string, passphrase string, privKey crypto.PrivKey) (Info, error) {
+	if !isValidName(name) {
+		return Info{}, errors.New("Invalid key name " + name)
+	}
+	if _, err := os.Stat(ks.keyPath(name)); err == nil {
+		return Info{}, errors.New("Key already exists: " + name)
+	}
+	kf, err := encrypt(name, passphrase, privKey)
+	if err != nil {
+		return Info{}, err
+	}
+	if err := os.MkdirAll(ks.dir, 0700); err != nil {
+		return Info{}, err
+	}
+	if err := ioutil.WriteFile(ks.keyPath(name), wire.JSONBytes(kf), 0600); err != nil {
+		return Info{}, err
+	}
+	return kf.Info, nil
+}
+
+func (ks *Keystore) load(name string) (*keyFile, error) {
+	if !isValidName(name) {
+		return nil, errors.New("Invalid key name " + name)
+	}
+	data, err := ioutil.ReadFile(ks.keyPath(name))
+	if os.IsNotExist(err) {
+		return nil, errors.New("Key not found: " + name)
+	} else if err != nil {
+		return nil, err
+	}
+	var kf *keyFile
+	wire.ReadJSONPtr(&kf, data, &err)
+	if err != nil {
+		return nil, errors.New(Fmt("Error decoding key %v: %v", name, err.Error()))
+	}
+	return kf, nil
+}
+
+func (ks *Keystore) unlock(name string, passphrase string) (crypto.PrivKey, error) {
+	kf, err := ks.load(name)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(kf, passphrase)
+}
+
+func encrypt(name string, passphrase string, privKey crypto.PrivKey) (*keyFile, error) {
+	salt := crypto.CRandBytes(saltLength)
+	secret, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, secretLength)
+	if err != nil {
+		return nil, err
+	}
+	var key [secretLength]byte
+	var nonce [nonceLength]byte
+	copy(key[:], secret)
+	copy(nonce[:], crypto.CRandBytes(nonceLength))
+	return &keyFile{
+		Info: Info{
+			Name:    name,
+			Address: privKey.PubKey().Address(),
+			PubKey:  privKey.PubKey(),
+		},
+		Salt:       salt,
+		Nonce:      nonce[:],
+		Ciphertext: secretbox.Seal(nil, privKey.Bytes(), &nonce, &key),
+	}, nil
+}
+
+func decrypt(kf *keyFile, passphrase string) (crypto.PrivKey, error) {
+	if kf == nil || len(kf.Nonce) != nonceLength {
+		return nil, errors.New("Invalid key file")
+	}
+	secret, err := scrypt.Key([]byte(passphrase), kf.Salt, scryptN, scryptR, scryptP, secretLength)
+	if err != nil {
+		return nil, err
+	}
+	var key [secretLength]byte
+	var nonce [nonceLength]byte
+	copy(key[:], secret)
+	copy(nonce[:], kf.Nonce)
+	privKeyBytes, ok := secretbox.Open(nil, kf.Ciphertext, &nonce, &key)
+	if !ok {
+		return nil, ErrWrongPassphrase
+	}
+	var privKey crypto.PrivKey
+	if err := wire.ReadBinaryBytes(privKeyBytes, &privKey); err != nil {
+		return nil, errors.New("Error decoding private key: " + err.Error())
+	}
+	if !bytes.Equal(privKey.PubKey().Address(), kf.Info.Address) {
+		return nil, errors.New("Private key does not match address")
+	}
+	return privKey, nil
+}
+
+// Names are file names, so only letters, digits, '-' and '_' are allowed.
+func isValidName(name string) bool {
+	if len(name) == 0 || len(name) > maxNameLength {
+		return false
+	}
+	for _, c := range name {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+type infosByName []Info
+
+func (infos infosByName) Len() int           { return len(infos) }
+func (infos infosByName) Less(i, j int) bool { return infos[i].Name < infos[j].Name }
+func (infos infosByName) Swap(i, j int)      { infos[i], infos[j] = infos[j], infos[i] }
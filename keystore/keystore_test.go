@@ -0,0 +1,88 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/tendermint/basecoin/types"
+)
+
+func newTestKeystore(t *testing.T) (*Keystore, func()) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(dir), func() { os.RemoveAll(dir) }
+}
+
+func TestKeystoreCreateAndSign(t *testing.T) {
+	ks, cleanup := newTestKeystore(t)
+	defer cleanup()
+
+	info, err := ks.Create("alice", "secret")
+	if err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+	if _, err := ks.Create("alice", "secret"); err == nil {
+		t.Error("Expected creating a duplicate name to fail")
+	}
+	if _, err := ks.Create("../alice", "secret"); err == nil {
+		t.Error("Expected creating an invalid name to fail")
+	}
+	infos, err := ks.List()
+	if err != nil || len(infos) != 1 || infos[0].Name != "alice" {
+		t.Fatalf("Expected to list alice, got %v %v", infos, err)
+	}
+
+	tx := &types.SendTx{
+		Inputs: []types.TxInput{{
+			Address:  info.Address,
+			Coins:    types.Coins{{"", 10}},
+			Sequence: 1,
+			PubKey:   info.PubKey,
+		}},
+		Outputs: []types.TxOutput{{
+			Address: []byte("recipient_address_00"),
+			Coins:   types.Coins{{"", 10}},
+		}},
+	}
+	if err := ks.SignTx("alice", "wrong", "test_chain_id", tx); err != ErrWrongPassphrase {
+		t.Fatalf("Expected a wrong passphrase error, got %v", err)
+	}
+	if err := ks.SignTx("alice", "secret", "test_chain_id", tx); err != nil {
+		t.Fatalf("Unexpected error signing: %v", err)
+	}
+	if !info.PubKey.VerifyBytes(tx.SignBytes("test_chain_id"), tx.Inputs[0].Signature) {
+		t.Error("Expected a valid signature on the input")
+	}
+}
+
+func TestKeystoreExportImport(t *testing.T) {
+	ks, cleanup := newTestKeystore(t)
+	defer cleanup()
+	other, cleanupOther := newTestKeystore(t)
+	defer cleanupOther()
+
+	info, err := ks.Create("alice", "secret")
+	if err != nil {
+		t.Fatalf("Unexpected error creating key: %v", err)
+	}
+	exported, err := ks.Export("alice", "secret", "transfer")
+	if err != nil {
+		t.Fatalf("Unexpected error exporting key: %v", err)
+	}
+	if _, err := other.Import("bob", "new", exported, "secret"); err != ErrWrongPassphrase {
+		t.Fatalf("Expected a wrong passphrase error, got %v", err)
+	}
+	imported, err := other.Import("bob", "new", exported, "transfer")
+	if err != nil {
+		t.Fatalf("Unexpected error importing key: %v", err)
+	}
+	if string(imported.Address) != string(info.Address) {
+		t.Errorf("Expected imported address %X, got %X", info.Address, imported.Address)
+	}
+	if _, err := other.Sign("bob", "new", []byte("msg")); err != nil {
+		t.Errorf("Unexpected error signing with imported key: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tendermint/basecoin/types"
+	"github.com/tendermint/go-events"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+// Upper bounds of the RunTx latency buckets, in seconds.
+var runTxBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+/*
+Metrics counts what the app does, and writes it out in the Prometheus
+text format. It is safe to use from the TMSP connections and the HTTP
+server at once.
+
+Metrics is an events.Fireable, so ExecTx can report plugin RunTx latency
+through its event argument.
+*/
+type Metrics struct {
+	mtx         sync.Mutex
+	txs         map[txLabels]uint64
+	rejections  map[string]uint64 // By reason
+	runTx       map[string]*histogram
+	cacheHits   uint64
+	cacheMisses uint64
+	height      uint64
+}
+
+type txLabels struct {
+	method string
+	code   string
+}
+
+type histogram struct {
+	counts []uint64 // One per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		txs:        make(map[txLabels]uint64),
+		rejections: make(map[string]uint64),
+		runTx:      make(map[string]*histogram),
+	}
+}
+
+// Counts the result of a CheckTx or AppendTx.
+func (m *Metrics) CountTx(method string, code tmsp.CodeType) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.txs[txLabels{method, code.String()}] += 1
+}
+
+// Counts a tx kept out of the mempool by CheckTx.
+func (m *Metrics) CountRejection(reason string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.rejections[reason] += 1
+}
+
+func (m *Metrics) ObserveRunTx(pluginName string, duration time.Duration) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	h := m.runTx[pluginName]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(runTxBuckets))}
+		m.runTx[pluginName] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range runTxBuckets {
+		if seconds <= bound {
+			h.counts[i] += 1
+			break
+		}
+	}
+	h.count += 1
+	h.sum += seconds
+}
+
+// Adds the hits and misses of a KVCache that is no longer used.
+func (m *Metrics) AddCacheStats(hits, misses uint64) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.cacheHits += hits
+	m.cacheMisses += misses
+}
+
+func (m *Metrics) SetHeight(height uint64) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.height = height
+}
+
+// Implements events.Fireable
+func (m *Metrics) FireEvent(event string, data events.EventData) {
+	switch data := data.(type) {
+	case types.EventDataRunTx:
+		m.ObserveRunTx(data.Plugin, data.Duration)
+	}
+}
+
+// Implements http.Handler
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteText(w)
+}
+
+// Writes every metric in the Prometheus text format, with labels sorted
+// so the output is stable.
+func (m *Metrics) WriteText(w io.Writer) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	writeHeader(w, "basecoin_txs_total", "counter", "Txs processed, by TMSP method and result code.")
+	txLabelz := make([]txLabels, 0, len(m.txs))
+	for labels := range m.txs {
+		txLabelz = append(txLabelz, labels)
+	}
+	sort.Sort(byMethodAndCode(txLabelz))
+	for _, labels := range txLabelz {
+		fmt.Fprintf(w, "basecoin_txs_total{method=%q,code=%q} %v\n",
+			labels.method, labels.code, m.txs[labels])
+	}
+
+	writeHeader(w, "basecoin_mempool_rejections_total", "counter", "Txs rejected by CheckTx, by reason.")
+	for _, reason := range sortedKeys(m.rejections) {
+		fmt.Fprintf(w, "basecoin_mempool_rejections_total{reason=%q} %v\n",
+			reason, m.rejections[reason])
+	}
+
+	writeHeader(w, "basecoin_run_tx_duration_seconds", "histogram", "Time plugins spend in RunTx, by plugin.")
+	pluginNames := make([]string, 0, len(m.runTx))
+	for name := range m.runTx {
+		pluginNames = append(pluginNames, name)
+	}
+	sort.Strings(pluginNames)
+	for _, name := range pluginNames {
+		h := m.runTx[name]
+		cumulative := uint64(0)
+		for i, bound := range runTxBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "basecoin_run_tx_duration_seconds_bucket{plugin=%q,le=\"%v\"} %v\n",
+				name, bound, cumulative)
+		}
+		fmt.Fprintf(w, "basecoin_run_tx_duration_seconds_bucket{plugin=%q,le=\"+Inf\"} %v\n", name, h.count)
+		fmt.Fprintf(w, "basecoin_run_tx_duration_seconds_sum{plugin=%q} %v\n", name, h.sum)
+		fmt.Fprintf(w, "basecoin_run_tx_duration_seconds_count{plugin=%q} %v\n", name, h.count)
+	}
+
+	writeHeader(w, "basecoin_kvcache_hits_total", "counter", "KVCache gets answered from the cache.")
+	fmt.Fprintf(w, "basecoin_kvcache_hits_total %v\n", m.cacheHits)
+	writeHeader(w, "basecoin_kvcache_misses_total", "counter", "KVCache gets that went to the store.")
+	fmt.Fprintf(w, "basecoin_kvcache_misses_total %v\n", m.cacheMisses)
+	writeHeader(w, "basecoin_kvcache_hit_ratio", "gauge", "Share of KVCache gets answered from the cache.")
+	ratio := 0.0
+	if total := m.cacheHits + m.cacheMisses; total > 0 {
+		ratio = float64(m.cacheHits) / float64(total)
+	}
+	fmt.Fprintf(w, "basecoin_kvcache_hit_ratio %v\n", ratio)
+
+	writeHeader(w, "basecoin_block_height", "gauge", "Height of the last committed block.")
+	fmt.Fprintf(w, "basecoin_block_height %v\n", m.height)
+}
+
+//----------------------------------------
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %v %v\n", name, help)
+	fmt.Fprintf(w, "# TYPE %v %v\n", name, kind)
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type byMethodAndCode []txLabels
+
+func (l byMethodAndCode) Len() int      { return len(l) }
+func (l byMethodAndCode) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l byMethodAndCode) Less(i, j int) bool {
+	if l[i].method != l[j].method {
+		return l[i].method < l[j].method
+	}
+	return l[i].code < l[j].code
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tendermint/basecoin/types"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+func TestWriteText(t *testing.T) {
+	m := NewMetrics()
+	m.CountTx("check_tx", tmsp.CodeType_OK)
+	m.CountTx("check_tx", tmsp.CodeType_OK)
+	m.CountTx("check_tx", tmsp.CodeType_BaseEncodingError)
+	m.CountRejection("decode_error")
+	m.FireEvent(types.EventStringRunTx("escrow"), types.EventDataRunTx{
+		Plugin:   "escrow",
+		Duration: 2 * time.Millisecond,
+		Code:     tmsp.CodeType_OK,
+	})
+	m.AddCacheStats(3, 1)
+	m.SetHeight(7)
+
+	buf := new(bytes.Buffer)
+	m.WriteText(buf)
+	text := buf.String()
+	for _, line := range []string{
+		`basecoin_txs_total{method="check_tx",code="OK"} 2`,
+		`basecoin_txs_total{method="check_tx",code="BaseEncodingError"} 1`,
+		`basecoin_mempool_rejections_total{reason="decode_error"} 1`,
+		`basecoin_run_tx_duration_seconds_bucket{plugin="escrow",le="0.001"} 0`,
+		`basecoin_run_tx_duration_seconds_bucket{plugin="escrow",le="0.0025"} 1`,
+		`basecoin_run_tx_duration_seconds_bucket{plugin="escrow",le="+Inf"} 1`,
+		`basecoin_run_tx_duration_seconds_count{plugin="escrow"} 1`,
+		`basecoin_kvcache_hit_ratio 0.75`,
+		`basecoin_block_height 7`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Expected line %q in:\n%v", line, text)
+		}
+	}
+}
@@ -0,0 +1,464 @@
+package coingov
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+const (
+	defaultMinDeposit   = 1000
+	defaultVotingPeriod = 1000
+	defaultQuorum       = 33 // Percent of the supply that must vote
+	defaultThreshold    = 50 // Percent of yes and no votes that must be yes
+)
+
+/*
+Tx is the data of an AppTx sent to the coingov plugin.
+
+  - ProposeTx     Submit a proposal, with the call coins as deposit
+  - VoteTx        Vote on a proposal, weighted by balance when it started
+*/
+type Tx interface {
+	AssertIsTx()
+}
+
+const (
+	TxTypePropose = byte(0x01)
+	TxTypeVote    = byte(0x02)
+)
+
+func (_ *ProposeTx) AssertIsTx() {}
+func (_ *VoteTx) AssertIsTx()    {}
+
+var _ = wire.RegisterInterface(
+	struct{ Tx }{},
+	wire.ConcreteType{&ProposeTx{}, TxTypePropose},
+	wire.ConcreteType{&VoteTx{}, TxTypeVote},
+)
+
+// Encodes tx as the data of an AppTx.
+func TxBytes(tx Tx) []byte {
+	return wire.BinaryBytes(struct{ Tx }{tx})
+}
+
+// A text proposal has no Change.
+type ProposeTx struct {
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Change      *ParamChange `json:"change"`
+}
+
+type VoteTx struct {
+	ProposalID uint64 `json:"proposal_id"`
+	Option     byte   `json:"option"`
+}
+
+// A ParamChange sets a plugin option, in the same
+// "plugin/key" form as genesis, once its proposal passes.
+// Only keys listed in the "changeable" option can be proposed.
+type ParamChange struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+//----------------------------------------
+
+const (
+	OptionYes     = byte(0x01)
+	OptionNo      = byte(0x02)
+	OptionAbstain = byte(0x03)
+)
+
+const (
+	StatusVoting   = byte(0x01)
+	StatusPassed   = byte(0x02)
+	StatusRejected = byte(0x03)
+)
+
+type Proposal struct {
+	ID          uint64       `json:"id"`
+	Proposer    []byte       `json:"proposer"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Change      *ParamChange `json:"change"`
+	Deposit     types.Coins  `json:"deposit"`
+	StartHeight uint64       `json:"start_height"`
+	EndHeight   uint64       `json:"end_height"`
+	Supply      int64        `json:"supply"`    // Of the voting denom when the proposal started
+	Quorum      int64        `json:"quorum"`    // Percent, as when the proposal started
+	Threshold   int64        `json:"threshold"` // Percent, as when the proposal started
+	Yes         int64        `json:"yes"`
+	No          int64        `json:"no"`
+	Abstain     int64        `json:"abstain"`
+	Status      byte         `json:"status"`
+	Log         string       `json:"log"` // Result of applying Change
+}
+
+// Abstain votes count towards the quorum only.
+// Computed with big.Int, as the products overflow an int64 for large supplies.
+func (p *Proposal) isPassed() bool {
+	yes, no, abstain := big.NewInt(p.Yes), big.NewInt(p.No), big.NewInt(p.Abstain)
+	voted := new(big.Int).Add(yes, no)
+	voted.Add(voted, abstain)
+	if mul(voted, 100).Cmp(mul(big.NewInt(p.Supply), p.Quorum)) < 0 {
+		return false
+	}
+	return mul(yes, 100).Cmp(mul(new(big.Int).Add(yes, no), p.Threshold)) > 0
+}
+
+func mul(x *big.Int, y int64) *big.Int {
+	return new(big.Int).Mul(x, big.NewInt(y))
+}
+
+//----------------------------------------
+
+// OptionSetter applies a ParamChange, returning "Success" or an error log.
+type OptionSetter func(store types.KVStore, key string, value string) (log string)
+
+type CoinGovPlugin struct {
+	name      string
+	height    uint64
+	setOption OptionSetter
+}
+
+func New(name string, setOption OptionSetter) *CoinGovPlugin {
+	return &CoinGovPlugin{
+		name:      name,
+		setOption: setOption,
+	}
+}
+
+func (cp *CoinGovPlugin) Name() string {
+	return cp.name
+}
+
+func (cp *CoinGovPlugin) ProposalKey(id uint64) []byte {
+	return []byte(Fmt("%v/p/%v", cp.name, id))
+}
+
+func (cp *CoinGovPlugin) VoteKey(id uint64, addr []byte) []byte {
+	return append([]byte(Fmt("%v/v/%v/", cp.name, id)), addr...)
+}
+
+func (cp *CoinGovPlugin) endKey(height uint64) []byte {
+	return []byte(Fmt("%v/e/%v", cp.name, height))
+}
+
+func (cp *CoinGovPlugin) counterKey() []byte {
+	return []byte(cp.name + "/n")
+}
+
+func (cp *CoinGovPlugin) paramKey(param string) []byte {
+	return []byte(cp.name + "/" + param)
+}
+
+// Balances are snapshotted per proposal.
+func (cp *CoinGovPlugin) snapshotID(id uint64) string {
+	return Fmt("%v/%v", cp.name, id)
+}
+
+// Returns the denom of the coins that carry votes and pay deposits.
+func (cp *CoinGovPlugin) GetDenom(store types.KVStore) string {
+	return string(store.Get(cp.paramKey("denom")))
+}
+
+func (cp *CoinGovPlugin) GetMinDeposit(store types.KVStore) int64 {
+	data := store.Get(cp.paramKey("min_deposit"))
+	if len(data) == 0 {
+		return defaultMinDeposit
+	}
+	var deposit int64
+	cp.readBinary(data, &deposit)
+	return deposit
+}
+
+func (cp *CoinGovPlugin) GetVotingPeriod(store types.KVStore) uint64 {
+	data := store.Get(cp.paramKey("voting_period"))
+	if len(data) == 0 {
+		return defaultVotingPeriod
+	}
+	var period uint64
+	cp.readBinary(data, &period)
+	return period
+}
+
+func (cp *CoinGovPlugin) GetQuorum(store types.KVStore) int64 {
+	return cp.getPercent(store, "quorum", defaultQuorum)
+}
+
+func (cp *CoinGovPlugin) GetThreshold(store types.KVStore) int64 {
+	return cp.getPercent(store, "threshold", defaultThreshold)
+}
+
+func (cp *CoinGovPlugin) getPercent(store types.KVStore, param string, def int64) int64 {
+	data := store.Get(cp.paramKey(param))
+	if len(data) == 0 {
+		return def
+	}
+	var percent int64
+	cp.readBinary(data, &percent)
+	return percent
+}
+
+// Returns whether a ParamChange may set key, in "plugin/key" form.
+func (cp *CoinGovPlugin) IsChangeable(store types.KVStore, key string) bool {
+	for _, changeable := range strings.Split(string(store.Get(cp.paramKey("changeable"))), ",") {
+		if changeable == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (cp *CoinGovPlugin) GetProposal(store types.KVStore, id uint64) *Proposal {
+	data := store.Get(cp.ProposalKey(id))
+	if len(data) == 0 {
+		return nil
+	}
+	var proposal *Proposal
+	cp.readBinary(data, &proposal)
+	return proposal
+}
+
+func (cp *CoinGovPlugin) setProposal(store types.KVStore, proposal *Proposal) {
+	store.Set(cp.ProposalKey(proposal.ID), wire.BinaryBytes(proposal))
+}
+
+func (cp *CoinGovPlugin) SetOption(store types.KVStore, key string, value string) (log string) {
+	switch key {
+	case "denom":
+		store.Set(cp.paramKey(key), []byte(value))
+		return "Success"
+	case "min_deposit":
+		deposit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || deposit < 0 {
+			return "Invalid min deposit " + value
+		}
+		store.Set(cp.paramKey(key), wire.BinaryBytes(deposit))
+		return "Success"
+	case "voting_period":
+		period, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || period == 0 {
+			return "Invalid voting period " + value
+		}
+		store.Set(cp.paramKey(key), wire.BinaryBytes(period))
+		return "Success"
+	case "quorum", "threshold":
+		percent, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return "Invalid " + key + " " + value
+		}
+		store.Set(cp.paramKey(key), wire.BinaryBytes(percent))
+		return "Success"
+	case "changeable":
+		// A comma separated list of plugin/key options.
+		for _, changeable := range strings.Split(value, ",") {
+			if !strings.Contains(changeable, "/") {
+				return "Invalid changeable option " + changeable
+			}
+		}
+		store.Set(cp.paramKey(key), []byte(value))
+		return "Success"
+	}
+	return "Unrecognized option key " + key
+}
+
+func (cp *CoinGovPlugin) CheckTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	_, res = cp.validateTx(store, ctx, txBytes)
+	return res
+}
+
+func (cp *CoinGovPlugin) RunTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	tx, res := cp.validateTx(store, ctx, txBytes)
+	if res.IsErr() {
+		return res
+	}
+
+	switch tx := tx.(type) {
+	case *ProposeTx:
+		proposal := &Proposal{
+			ID:          cp.nextID(store),
+			Proposer:    ctx.Caller,
+			Title:       tx.Title,
+			Description: tx.Description,
+			Change:      tx.Change,
+			Deposit:     ctx.Coins,
+			StartHeight: cp.height,
+			EndHeight:   cp.height + cp.GetVotingPeriod(store),
+			Supply:      sm.GetSupply(store, cp.GetDenom(store)),
+			Quorum:      cp.GetQuorum(store),
+			Threshold:   cp.GetThreshold(store),
+			Status:      StatusVoting,
+		}
+		cp.setProposal(store, proposal)
+		cp.addEnding(store, proposal.EndHeight, proposal.ID)
+		sm.TakeSnapshot(store, cp.snapshotID(proposal.ID))
+		// The deposit stays in the plugin account until the tally.
+		return tmsp.NewResultOK(wire.BinaryBytes(proposal.ID), "")
+	case *VoteTx:
+		proposal := cp.GetProposal(store, tx.ProposalID)
+		weight := cp.votingPower(store, proposal.ID, ctx.Caller)
+		switch tx.Option {
+		case OptionYes:
+			proposal.Yes += weight
+		case OptionNo:
+			proposal.No += weight
+		case OptionAbstain:
+			proposal.Abstain += weight
+		}
+		cp.setProposal(store, proposal)
+		store.Set(cp.VoteKey(proposal.ID, ctx.Caller), []byte{tx.Option})
+	}
+
+	// Nothing to deposit, give the call coins back.
+	cp.pay(store, ctx.Caller, ctx.Coins)
+	return tmsp.OK
+}
+
+// Query is the binary encoded ID of a proposal.
+func (cp *CoinGovPlugin) Query(store types.KVStore, query []byte) (res tmsp.Result) {
+	var id uint64
+	err := wire.ReadBinaryBytes(query, &id)
+	if err != nil {
+		return tmsp.ErrEncodingError.AppendLog("Error decoding query: " + err.Error())
+	}
+	proposal := cp.GetProposal(store, id)
+	if proposal == nil {
+		return tmsp.ErrUnknownRequest.AppendLog(Fmt("Proposal %v not found", id))
+	}
+	return tmsp.NewResultOK(wire.BinaryBytes(proposal), "")
+}
+
+func (cp *CoinGovPlugin) InitChain(store types.KVStore, vals []*tmsp.Validator) {
+}
+
+func (cp *CoinGovPlugin) BeginBlock(store types.KVStore, height uint64) {
+	cp.height = height
+}
+
+// Tallies every proposal whose voting period ends at height.
+// A proposal passes if enough of the supply voted and
+// enough of the yes and no votes were yes.
+func (cp *CoinGovPlugin) EndBlock(store types.KVStore, height uint64) []*tmsp.Validator {
+	ids := cp.getEndings(store, height)
+	if len(ids) == 0 {
+		return nil
+	}
+	for _, id := range ids {
+		proposal := cp.GetProposal(store, id)
+		if proposal.isPassed() {
+			proposal.Status = StatusPassed
+			if proposal.Change != nil {
+				proposal.Log = cp.setOption(store, proposal.Change.Key, proposal.Change.Value)
+			}
+		} else {
+			proposal.Status = StatusRejected
+		}
+		cp.setProposal(store, proposal)
+		cp.pay(store, proposal.Proposer, proposal.Deposit)
+		sm.ReleaseSnapshot(store, cp.snapshotID(id))
+	}
+	store.Set(cp.endKey(height), nil)
+	return nil
+}
+
+//----------------------------------------
+
+// Decodes the tx and checks the deposit of a proposal, or that the
+// caller had voting power and hasn't voted yet.
+func (cp *CoinGovPlugin) validateTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (Tx, tmsp.Result) {
+	var tx Tx
+	err := wire.ReadBinaryBytes(txBytes, &tx)
+	if err != nil {
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Error decoding tx: " + err.Error())
+	}
+
+	switch tx := tx.(type) {
+	case *ProposeTx:
+		if tx.Title == "" {
+			return nil, tmsp.ErrEncodingError.AppendLog("Title cannot be empty")
+		}
+		if tx.Change != nil && !cp.IsChangeable(store, tx.Change.Key) {
+			return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Option %v cannot be changed", tx.Change.Key))
+		}
+		deposit := types.Coins{{cp.GetDenom(store), cp.GetMinDeposit(store)}}
+		if deposit[0].Amount != 0 && !ctx.Coins.IsGTE(deposit) {
+			return nil, tmsp.ErrBaseInsufficientFunds.AppendLog(Fmt("Deposit must be at least %v", deposit))
+		}
+	case *VoteTx:
+		proposal := cp.GetProposal(store, tx.ProposalID)
+		if proposal == nil {
+			return nil, tmsp.ErrUnknownRequest.AppendLog(Fmt("Proposal %v not found", tx.ProposalID))
+		}
+		if proposal.Status != StatusVoting {
+			return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Voting on proposal %v has ended", tx.ProposalID))
+		}
+		if tx.Option != OptionYes && tx.Option != OptionNo && tx.Option != OptionAbstain {
+			return nil, tmsp.ErrEncodingError.AppendLog(Fmt("Invalid vote option %v", tx.Option))
+		}
+		if len(store.Get(cp.VoteKey(proposal.ID, ctx.Caller))) != 0 {
+			return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Already voted on proposal %v", tx.ProposalID))
+		}
+		if cp.votingPower(store, proposal.ID, ctx.Caller) <= 0 {
+			return nil, tmsp.ErrUnauthorized.AppendLog("No voting power at the start of the proposal")
+		}
+	default:
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Unknown tx type")
+	}
+	return tx, tmsp.OK
+}
+
+// Returns how many coins of the voting denom addr held when the proposal started.
+func (cp *CoinGovPlugin) votingPower(store types.KVStore, id uint64, addr []byte) int64 {
+	denom := cp.GetDenom(store)
+	for _, coin := range sm.GetSnapshotBalance(store, cp.snapshotID(id), addr) {
+		if coin.Denom == denom {
+			return coin.Amount
+		}
+	}
+	return 0
+}
+
+func (cp *CoinGovPlugin) pay(store types.KVStore, addr []byte, coins types.Coins) {
+	if !sm.TransferCoins(store, types.PluginAddress(cp.name), addr, coins) {
+		PanicSanity(Fmt("Plugin account should hold %v", coins))
+	}
+}
+
+func (cp *CoinGovPlugin) nextID(store types.KVStore) uint64 {
+	var id uint64
+	if data := store.Get(cp.counterKey()); len(data) != 0 {
+		cp.readBinary(data, &id)
+	}
+	id += 1
+	store.Set(cp.counterKey(), wire.BinaryBytes(id))
+	return id
+}
+
+func (cp *CoinGovPlugin) getEndings(store types.KVStore, height uint64) (ids []uint64) {
+	data := store.Get(cp.endKey(height))
+	if len(data) == 0 {
+		return nil
+	}
+	cp.readBinary(data, &ids)
+	return ids
+}
+
+func (cp *CoinGovPlugin) addEnding(store types.KVStore, height uint64, id uint64) {
+	ids := append(cp.getEndings(store, height), id)
+	store.Set(cp.endKey(height), wire.BinaryBytes(ids))
+}
+
+func (cp *CoinGovPlugin) readBinary(data []byte, ptr interface{}) {
+	err := wire.ReadBinaryBytes(data, ptr)
+	if err != nil {
+		panic(Fmt("Error reading %v state %X error: %v", cp.name, data, err.Error()))
+	}
+}
@@ -0,0 +1,89 @@
+package coingov
+
+import (
+	"testing"
+
+	"github.com/tendermint/basecoin/plugins/plugintest"
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	"github.com/tendermint/go-wire"
+)
+
+func TestCoinGovVoteWithSnapshot(t *testing.T) {
+	store := types.NewMemKVStore()
+	var changed []string
+	cp := New("coingov", func(store types.KVStore, key string, value string) string {
+		changed = append(changed, key+"="+value)
+		return "Success"
+	})
+	cp.SetOption(store, "min_deposit", "10")
+	cp.SetOption(store, "voting_period", "10")
+	cp.SetOption(store, "changeable", "names/fee,names/period")
+
+	proposer := []byte("proposer_address_000")
+	whale := []byte("whale_address_000000")
+	minnow := []byte("minnow_address_00000")
+	sm.MintCoins(store, whale, types.Coins{{"", 100}})
+	sm.MintCoins(store, minnow, types.Coins{{"", 30}})
+
+	cp.BeginBlock(store, 1)
+	if res := plugintest.RunTx(cp, store, proposer, types.Coins{{"", 5}}, TxBytes(&ProposeTx{Title: "cheap"})); res.IsOK() {
+		t.Fatal("Expected a proposal below the min deposit to be rejected")
+	}
+	minting := &ProposeTx{Title: "mint", Change: &ParamChange{"faucet/fund", "1000000"}}
+	if res := plugintest.RunTx(cp, store, proposer, types.Coins{{"", 10}}, TxBytes(minting)); res.IsOK() {
+		t.Fatal("Expected a proposal to change an option that isn't changeable to be rejected")
+	}
+	propose := &ProposeTx{Title: "fee", Change: &ParamChange{"names/fee", "20"}}
+	res := plugintest.RunTx(cp, store, proposer, types.Coins{{"", 10}}, TxBytes(propose))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error proposing: %v", res)
+	}
+	var id uint64
+	if err := wire.ReadBinaryBytes(res.Data, &id); err != nil {
+		t.Fatalf("Unexpected result data %X: %v", res.Data, err)
+	}
+
+	// Coins moved after the proposal started don't carry extra votes.
+	sm.TransferCoins(store, whale, minnow, types.Coins{{"", 90}})
+	if res := plugintest.RunTx(cp, store, minnow, nil, TxBytes(&VoteTx{id, OptionYes})); res.IsErr() {
+		t.Fatalf("Unexpected error voting: %v", res)
+	}
+	if res := plugintest.RunTx(cp, store, whale, nil, TxBytes(&VoteTx{id, OptionNo})); res.IsErr() {
+		t.Fatalf("Unexpected error voting: %v", res)
+	}
+	if res := plugintest.RunTx(cp, store, whale, nil, TxBytes(&VoteTx{id, OptionYes})); res.IsOK() {
+		t.Fatal("Expected a second vote to be rejected")
+	}
+
+	cp.EndBlock(store, 11)
+	proposal := cp.GetProposal(store, id)
+	if proposal.Yes != 30 || proposal.No != 100 {
+		t.Errorf("Expected 30 yes and 100 no, got %v and %v", proposal.Yes, proposal.No)
+	}
+	if proposal.Status != StatusRejected || len(changed) != 0 {
+		t.Errorf("Expected proposal to be rejected without changes, got status %v and %v", proposal.Status, changed)
+	}
+	if acc := sm.GetAccount(store, proposer); !acc.Balance.IsEqual(types.Coins{{"", 10}}) {
+		t.Errorf("Expected the deposit to be refunded, got %v", acc.Balance)
+	}
+	for _, addr := range [][]byte{whale, minnow} {
+		if data := store.Get(sm.SnapshotKey(cp.snapshotID(id), addr)); len(data) != 0 {
+			t.Errorf("Expected the snapshot record of %s to be removed, got %X", addr, data)
+		}
+	}
+}
+
+func TestProposalIsPassedLargeSupply(t *testing.T) {
+	// A few genesis accounts of the 2^53 basecoin init gives, or so.
+	supply := int64(1) << 58
+	p := &Proposal{Supply: supply, Quorum: 33, Threshold: 50}
+	p.Yes = supply
+	if !p.isPassed() {
+		t.Error("Expected a proposal everyone voted yes on to pass")
+	}
+	p.Yes, p.No = supply/10, supply/10
+	if p.isPassed() {
+		t.Error("Expected a proposal with 20% turnout to miss the quorum")
+	}
+}
@@ -0,0 +1,320 @@
+package escrow
+
+import (
+	"bytes"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+/*
+Tx is the data of an AppTx sent to the escrow plugin.
+
+  - CreateTx      Lock the call coins for a recipient
+  - ReleaseTx     Pay an open escrow out to its recipient
+  - RefundTx      Return an open escrow to its sender
+*/
+type Tx interface {
+	AssertIsTx()
+}
+
+const (
+	TxTypeCreate  = byte(0x01)
+	TxTypeRelease = byte(0x02)
+	TxTypeRefund  = byte(0x03)
+)
+
+func (_ *CreateTx) AssertIsTx()  {}
+func (_ *ReleaseTx) AssertIsTx() {}
+func (_ *RefundTx) AssertIsTx()  {}
+
+var _ = wire.RegisterInterface(
+	struct{ Tx }{},
+	wire.ConcreteType{&CreateTx{}, TxTypeCreate},
+	wire.ConcreteType{&ReleaseTx{}, TxTypeRelease},
+	wire.ConcreteType{&RefundTx{}, TxTypeRefund},
+)
+
+// Encodes tx as the data of an AppTx.
+func TxBytes(tx Tx) []byte {
+	return wire.BinaryBytes(struct{ Tx }{tx})
+}
+
+type CreateTx struct {
+	Recipient []byte `json:"recipient"`
+	Arbiter   []byte `json:"arbiter"` // May be nil
+	Expiry    uint64 `json:"expiry"`  // Height at which the escrow is refunded, or 0 for never
+}
+
+type ReleaseTx struct {
+	ID uint64 `json:"id"`
+}
+
+type RefundTx struct {
+	ID uint64 `json:"id"`
+}
+
+//----------------------------------------
+
+const (
+	StatusOpen     = byte(0x01)
+	StatusReleased = byte(0x02)
+	StatusRefunded = byte(0x03)
+)
+
+type Escrow struct {
+	ID        uint64      `json:"id"`
+	Sender    []byte      `json:"sender"`
+	Recipient []byte      `json:"recipient"`
+	Arbiter   []byte      `json:"arbiter"`
+	Coins     types.Coins `json:"coins"`
+	Expiry    uint64      `json:"expiry"`
+	Status    byte        `json:"status"`
+}
+
+// The sender or the arbiter may release an escrow.
+func (e *Escrow) CanRelease(addr []byte) bool {
+	return bytes.Equal(addr, e.Sender) || bytes.Equal(addr, e.Arbiter)
+}
+
+// The arbiter may refund an escrow, as may the sender if there is no arbiter.
+// The recipient may always give the coins back.
+func (e *Escrow) CanRefund(addr []byte) bool {
+	if len(e.Arbiter) == 0 && bytes.Equal(addr, e.Sender) {
+		return true
+	}
+	return bytes.Equal(addr, e.Arbiter) || bytes.Equal(addr, e.Recipient)
+}
+
+//----------------------------------------
+
+type EscrowPlugin struct {
+	name   string
+	height uint64
+}
+
+func New(name string) *EscrowPlugin {
+	return &EscrowPlugin{
+		name: name,
+	}
+}
+
+func (ep *EscrowPlugin) Name() string {
+	return ep.name
+}
+
+func (ep *EscrowPlugin) EscrowKey(id uint64) []byte {
+	return []byte(Fmt("%v/e/%v", ep.name, id))
+}
+
+func (ep *EscrowPlugin) expiryKey(height uint64) []byte {
+	return []byte(Fmt("%v/x/%v", ep.name, height))
+}
+
+func (ep *EscrowPlugin) counterKey() []byte {
+	return []byte(ep.name + "/n")
+}
+
+func (ep *EscrowPlugin) GetEscrow(store types.KVStore, id uint64) *Escrow {
+	data := store.Get(ep.EscrowKey(id))
+	if len(data) == 0 {
+		return nil
+	}
+	var escrow *Escrow
+	err := wire.ReadBinaryBytes(data, &escrow)
+	if err != nil {
+		panic(Fmt("Error reading escrow %X error: %v", data, err.Error()))
+	}
+	return escrow
+}
+
+func (ep *EscrowPlugin) setEscrow(store types.KVStore, escrow *Escrow) {
+	store.Set(ep.EscrowKey(escrow.ID), wire.BinaryBytes(escrow))
+}
+
+func (ep *EscrowPlugin) SetOption(store types.KVStore, key string, value string) (log string) {
+	return "Unrecognized option key " + key
+}
+
+func (ep *EscrowPlugin) CheckTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	_, res = ep.validateTx(store, ctx, txBytes)
+	return res
+}
+
+func (ep *EscrowPlugin) RunTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	tx, res := ep.validateTx(store, ctx, txBytes)
+	if res.IsErr() {
+		return res
+	}
+
+	switch tx := tx.(type) {
+	case *CreateTx:
+		escrow := &Escrow{
+			ID:        ep.nextID(store),
+			Sender:    ctx.Caller,
+			Recipient: tx.Recipient,
+			Arbiter:   tx.Arbiter,
+			Coins:     ctx.Coins,
+			Expiry:    tx.Expiry,
+			Status:    StatusOpen,
+		}
+		ep.setEscrow(store, escrow)
+		if escrow.Expiry != 0 {
+			ep.addExpiry(store, escrow.Expiry, escrow.ID)
+		}
+		// The call coins are held until the escrow is released or refunded.
+		return tmsp.NewResultOK(wire.BinaryBytes(escrow.ID), "")
+	case *ReleaseTx:
+		escrow := ep.GetEscrow(store, tx.ID)
+		ep.settle(store, escrow, escrow.Recipient, StatusReleased)
+	case *RefundTx:
+		escrow := ep.GetEscrow(store, tx.ID)
+		ep.settle(store, escrow, escrow.Sender, StatusRefunded)
+	}
+
+	// Releases and refunds pay out of the escrow; their call coins go back.
+	ep.pay(store, ctx.Caller, ctx.Coins)
+	return tmsp.OK
+}
+
+// Query is the binary encoded ID of an escrow.
+func (ep *EscrowPlugin) Query(store types.KVStore, query []byte) (res tmsp.Result) {
+	var id uint64
+	err := wire.ReadBinaryBytes(query, &id)
+	if err != nil {
+		return tmsp.ErrEncodingError.AppendLog("Error decoding query: " + err.Error())
+	}
+	escrow := ep.GetEscrow(store, id)
+	if escrow == nil {
+		return tmsp.ErrUnknownRequest.AppendLog(Fmt("Escrow %v not found", id))
+	}
+	return tmsp.NewResultOK(wire.BinaryBytes(escrow), "")
+}
+
+func (ep *EscrowPlugin) InitChain(store types.KVStore, vals []*tmsp.Validator) {
+}
+
+func (ep *EscrowPlugin) BeginBlock(store types.KVStore, height uint64) {
+	ep.height = height
+}
+
+// Refunds every escrow that expires at height and is still open.
+func (ep *EscrowPlugin) EndBlock(store types.KVStore, height uint64) []*tmsp.Validator {
+	ids := ep.getExpiries(store, height)
+	if len(ids) == 0 {
+		return nil
+	}
+	for _, id := range ids {
+		escrow := ep.GetEscrow(store, id)
+		if escrow.Status == StatusOpen {
+			ep.settle(store, escrow, escrow.Sender, StatusRefunded)
+		}
+	}
+	store.Set(ep.expiryKey(height), nil)
+	return nil
+}
+
+//----------------------------------------
+
+// Decodes the tx and checks that only the parties allowed by the
+// escrow release or refund it while it's open.
+func (ep *EscrowPlugin) validateTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (Tx, tmsp.Result) {
+	var tx Tx
+	err := wire.ReadBinaryBytes(txBytes, &tx)
+	if err != nil {
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Error decoding tx: " + err.Error())
+	}
+
+	switch tx := tx.(type) {
+	case *CreateTx:
+		if len(tx.Recipient) != 20 {
+			return nil, tmsp.ErrBaseInvalidOutput.AppendLog("Invalid recipient address length")
+		}
+		if len(tx.Arbiter) != 0 && len(tx.Arbiter) != 20 {
+			return nil, tmsp.ErrBaseInvalidOutput.AppendLog("Invalid arbiter address length")
+		}
+		if tx.Expiry != 0 && tx.Expiry <= ep.height {
+			return nil, tmsp.ErrEncodingError.AppendLog(
+				Fmt("Expiry %v must be after the current height %v", tx.Expiry, ep.height))
+		}
+		if !ctx.Coins.IsPositive() {
+			return nil, tmsp.ErrBaseInsufficientFunds.AppendLog("Escrow must lock some coins")
+		}
+	case *ReleaseTx:
+		escrow, res := ep.getOpenEscrow(store, tx.ID)
+		if res.IsErr() {
+			return nil, res
+		}
+		if !escrow.CanRelease(ctx.Caller) {
+			return nil, tmsp.ErrUnauthorized.AppendLog("Only the sender or arbiter may release an escrow")
+		}
+	case *RefundTx:
+		escrow, res := ep.getOpenEscrow(store, tx.ID)
+		if res.IsErr() {
+			return nil, res
+		}
+		if !escrow.CanRefund(ctx.Caller) {
+			return nil, tmsp.ErrUnauthorized.AppendLog("Not allowed to refund this escrow")
+		}
+	default:
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Unknown tx type")
+	}
+	return tx, tmsp.OK
+}
+
+func (ep *EscrowPlugin) getOpenEscrow(store types.KVStore, id uint64) (*Escrow, tmsp.Result) {
+	escrow := ep.GetEscrow(store, id)
+	if escrow == nil {
+		return nil, tmsp.ErrUnknownRequest.AppendLog(Fmt("Escrow %v not found", id))
+	}
+	if escrow.Status != StatusOpen {
+		return nil, tmsp.ErrUnknownRequest.AppendLog(Fmt("Escrow %v is already settled", id))
+	}
+	return escrow, tmsp.OK
+}
+
+// Pays the escrowed coins to addr and closes the escrow.
+func (ep *EscrowPlugin) settle(store types.KVStore, escrow *Escrow, addr []byte, status byte) {
+	ep.pay(store, addr, escrow.Coins)
+	escrow.Status = status
+	ep.setEscrow(store, escrow)
+}
+
+func (ep *EscrowPlugin) pay(store types.KVStore, addr []byte, coins types.Coins) {
+	if !sm.TransferCoins(store, types.PluginAddress(ep.name), addr, coins) {
+		PanicSanity(Fmt("Plugin account should hold %v", coins))
+	}
+}
+
+func (ep *EscrowPlugin) nextID(store types.KVStore) uint64 {
+	var id uint64
+	if data := store.Get(ep.counterKey()); len(data) != 0 {
+		err := wire.ReadBinaryBytes(data, &id)
+		if err != nil {
+			panic(Fmt("Error reading escrow counter %X error: %v", data, err.Error()))
+		}
+	}
+	id += 1
+	store.Set(ep.counterKey(), wire.BinaryBytes(id))
+	return id
+}
+
+func (ep *EscrowPlugin) getExpiries(store types.KVStore, height uint64) (ids []uint64) {
+	data := store.Get(ep.expiryKey(height))
+	if len(data) == 0 {
+		return nil
+	}
+	err := wire.ReadBinaryBytes(data, &ids)
+	if err != nil {
+		panic(Fmt("Error reading expiries %X error: %v", data, err.Error()))
+	}
+	return ids
+}
+
+func (ep *EscrowPlugin) addExpiry(store types.KVStore, height uint64, id uint64) {
+	ids := append(ep.getExpiries(store, height), id)
+	store.Set(ep.expiryKey(height), wire.BinaryBytes(ids))
+}
@@ -0,0 +1,77 @@
+package escrow
+
+import (
+	"testing"
+
+	"github.com/tendermint/basecoin/plugins/plugintest"
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	"github.com/tendermint/go-wire"
+)
+
+var (
+	sender    = []byte("sender_address_00000")
+	recipient = []byte("recipient_address_00")
+	arbiter   = []byte("arbiter_address_0000")
+)
+
+func createEscrow(t *testing.T, ep *EscrowPlugin, store types.KVStore, expiry uint64) uint64 {
+	res := plugintest.RunTx(ep, store, sender, types.Coins{{"", 100}}, TxBytes(&CreateTx{
+		Recipient: recipient,
+		Arbiter:   arbiter,
+		Expiry:    expiry,
+	}))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error creating escrow: %v", res)
+	}
+	var id uint64
+	if err := wire.ReadBinaryBytes(res.Data, &id); err != nil {
+		t.Fatalf("Unexpected result data %X: %v", res.Data, err)
+	}
+	return id
+}
+
+func TestEscrowRelease(t *testing.T) {
+	store := types.NewMemKVStore()
+	ep := New("escrow")
+	ep.BeginBlock(store, 1)
+	id := createEscrow(t, ep, store, 0)
+
+	res := plugintest.RunTx(ep, store, recipient, types.Coins{{"", 1}}, TxBytes(&ReleaseTx{ID: id}))
+	if res.IsOK() {
+		t.Fatal("Expected recipient to be unable to release the escrow")
+	}
+	res = plugintest.RunTx(ep, store, sender, types.Coins{{"", 1}}, TxBytes(&RefundTx{ID: id}))
+	if res.IsOK() {
+		t.Fatal("Expected sender to be unable to refund an escrow with an arbiter")
+	}
+	res = plugintest.RunTx(ep, store, arbiter, types.Coins{{"", 1}}, TxBytes(&ReleaseTx{ID: id}))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error releasing escrow: %v", res)
+	}
+	if acc := sm.GetAccount(store, recipient); !acc.Balance.IsEqual(types.Coins{{"", 100}}) {
+		t.Errorf("Expected recipient to get 100, got %v", acc.Balance)
+	}
+	if escrow := ep.GetEscrow(store, id); escrow.Status != StatusReleased {
+		t.Errorf("Expected escrow to be released, got status %v", escrow.Status)
+	}
+}
+
+func TestEscrowExpiry(t *testing.T) {
+	store := types.NewMemKVStore()
+	ep := New("escrow")
+	ep.BeginBlock(store, 1)
+	id := createEscrow(t, ep, store, 5)
+
+	ep.EndBlock(store, 4)
+	if escrow := ep.GetEscrow(store, id); escrow.Status != StatusOpen {
+		t.Fatalf("Expected escrow to be open before expiry, got status %v", escrow.Status)
+	}
+	ep.EndBlock(store, 5)
+	if escrow := ep.GetEscrow(store, id); escrow.Status != StatusRefunded {
+		t.Fatalf("Expected escrow to be refunded at expiry, got status %v", escrow.Status)
+	}
+	if acc := sm.GetAccount(store, sender); !acc.Balance.IsEqual(types.Coins{{"", 100}}) {
+		t.Errorf("Expected sender to get 100 back, got %v", acc.Balance)
+	}
+}
@@ -0,0 +1,227 @@
+package eyes
+
+import (
+	"strconv"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+const (
+	defaultPricePerByte = 1
+)
+
+/*
+Tx is the data of an AppTx sent to the eyes plugin.
+
+  - SetTx         Store a value under one of the caller's keys, charging per byte stored
+  - GetTx         Read the value stored under an owner's key
+  - RemoveTx      Remove one of the caller's keys
+
+Keys are scoped to the address that sets them, so callers can't take
+each other's keys.
+*/
+type Tx interface {
+	AssertIsTx()
+}
+
+const (
+	TxTypeSet    = byte(0x01)
+	TxTypeGet    = byte(0x02)
+	TxTypeRemove = byte(0x03)
+)
+
+func (_ *SetTx) AssertIsTx()    {}
+func (_ *GetTx) AssertIsTx()    {}
+func (_ *RemoveTx) AssertIsTx() {}
+
+var _ = wire.RegisterInterface(
+	struct{ Tx }{},
+	wire.ConcreteType{&SetTx{}, TxTypeSet},
+	wire.ConcreteType{&GetTx{}, TxTypeGet},
+	wire.ConcreteType{&RemoveTx{}, TxTypeRemove},
+)
+
+// Encodes tx as the data of an AppTx.
+func TxBytes(tx Tx) []byte {
+	return wire.BinaryBytes(struct{ Tx }{tx})
+}
+
+type SetTx struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type GetTx struct {
+	Owner []byte `json:"owner"`
+	Key   []byte `json:"key"`
+}
+
+type RemoveTx struct {
+	Key []byte `json:"key"`
+}
+
+// Entry is what gets stored under each key.
+type Entry struct {
+	Value []byte `json:"value"`
+}
+
+//----------------------------------------
+
+type EyesPlugin struct {
+	name string
+}
+
+func New(name string) *EyesPlugin {
+	return &EyesPlugin{
+		name: name,
+	}
+}
+
+func (ep *EyesPlugin) Name() string {
+	return ep.name
+}
+
+func (ep *EyesPlugin) EntryKey(owner []byte, key []byte) []byte {
+	prefix := append([]byte(ep.name+"/k/"), owner...)
+	return append(append(prefix, '/'), key...)
+}
+
+func (ep *EyesPlugin) priceKey() []byte {
+	return []byte(ep.name + "/price")
+}
+
+// Returns the price, in the default coin, of storing one byte.
+func (ep *EyesPlugin) GetPricePerByte(store types.KVStore) int64 {
+	data := store.Get(ep.priceKey())
+	if len(data) == 0 {
+		return defaultPricePerByte
+	}
+	var price int64
+	err := wire.ReadBinaryBytes(data, &price)
+	if err != nil {
+		panic(Fmt("Error reading price %X error: %v", data, err.Error()))
+	}
+	return price
+}
+
+func (ep *EyesPlugin) GetEntry(store types.KVStore, owner []byte, key []byte) *Entry {
+	data := store.Get(ep.EntryKey(owner, key))
+	if len(data) == 0 {
+		return nil
+	}
+	var entry *Entry
+	err := wire.ReadBinaryBytes(data, &entry)
+	if err != nil {
+		panic(Fmt("Error reading entry %X error: %v", data, err.Error()))
+	}
+	return entry
+}
+
+func (ep *EyesPlugin) SetOption(store types.KVStore, key string, value string) (log string) {
+	switch key {
+	case "price":
+		price, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || price < 0 {
+			return "Invalid price " + value
+		}
+		store.Set(ep.priceKey(), wire.BinaryBytes(price))
+		return "Success"
+	}
+	return "Unrecognized option key " + key
+}
+
+func (ep *EyesPlugin) CheckTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	_, res = ep.validateTx(store, ctx, txBytes)
+	return res
+}
+
+func (ep *EyesPlugin) RunTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	tx, res := ep.validateTx(store, ctx, txBytes)
+	if res.IsErr() {
+		return res
+	}
+
+	var cost types.Coins
+	switch tx := tx.(type) {
+	case *SetTx:
+		cost = ep.cost(store, tx)
+		entry := &Entry{
+			Value: tx.Value,
+		}
+		store.Set(ep.EntryKey(ctx.Caller, tx.Key), wire.BinaryBytes(entry))
+	case *GetTx:
+		if entry := ep.GetEntry(store, tx.Owner, tx.Key); entry != nil {
+			res = tmsp.NewResultOK(entry.Value, "")
+		}
+	case *RemoveTx:
+		// An empty value deletes the key from the state and its index.
+		store.Set(ep.EntryKey(ctx.Caller, tx.Key), nil)
+	}
+
+	// Keep what we charged and return the rest to the caller.
+	refund := ctx.Coins.Minus(cost)
+	if !sm.TransferCoins(store, types.PluginAddress(ep.name), ctx.Caller, refund) {
+		PanicSanity("Plugin account should hold the call coins")
+	}
+	return res
+}
+
+func (ep *EyesPlugin) InitChain(store types.KVStore, vals []*tmsp.Validator) {
+}
+
+func (ep *EyesPlugin) BeginBlock(store types.KVStore, height uint64) {
+}
+
+func (ep *EyesPlugin) EndBlock(store types.KVStore, height uint64) []*tmsp.Validator {
+	return nil
+}
+
+//----------------------------------------
+
+// Decodes the tx and checks that a set pays for the bytes it stores
+// and that a removed key exists under the caller.
+func (ep *EyesPlugin) validateTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (Tx, tmsp.Result) {
+	var tx Tx
+	err := wire.ReadBinaryBytes(txBytes, &tx)
+	if err != nil {
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Error decoding tx: " + err.Error())
+	}
+
+	switch tx := tx.(type) {
+	case *SetTx:
+		if len(tx.Key) == 0 {
+			return nil, tmsp.ErrEncodingError.AppendLog("Key cannot be empty")
+		}
+		cost := ep.cost(store, tx)
+		if !ctx.Coins.IsGTE(cost) {
+			return nil, tmsp.ErrBaseInsufficientFunds.AppendLog(
+				Fmt("Storing %v bytes costs %v", len(tx.Key)+len(tx.Value), cost))
+		}
+	case *GetTx:
+		if len(tx.Owner) != 20 {
+			return nil, tmsp.ErrEncodingError.AppendLog("Invalid owner address length")
+		}
+		if len(tx.Key) == 0 {
+			return nil, tmsp.ErrEncodingError.AppendLog("Key cannot be empty")
+		}
+	case *RemoveTx:
+		if ep.GetEntry(store, ctx.Caller, tx.Key) == nil {
+			return nil, tmsp.ErrUnknownRequest.AppendLog(Fmt("Key %X not found", tx.Key))
+		}
+	default:
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Unknown tx type")
+	}
+	return tx, tmsp.OK
+}
+
+func (ep *EyesPlugin) cost(store types.KVStore, tx *SetTx) types.Coins {
+	amount := ep.GetPricePerByte(store) * int64(len(tx.Key)+len(tx.Value))
+	if amount == 0 {
+		return nil
+	}
+	return types.Coins{{"", amount}}
+}
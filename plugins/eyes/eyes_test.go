@@ -0,0 +1,70 @@
+package eyes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tendermint/basecoin/plugins/plugintest"
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+)
+
+func TestEyesKeysPerOwner(t *testing.T) {
+	store := sm.NewIndexedKVStore(types.NewMemKVStore())
+	ep := New("eyes")
+	alice, bob := []byte("alice_address_000000"), []byte("bob_address_00000000")
+
+	res := plugintest.RunTx(ep, store, alice, types.Coins{{"", 100}}, TxBytes(&SetTx{Key: []byte("k"), Value: []byte("v1")}))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error setting key: %v", res)
+	}
+	// Key and value are 3 bytes at the default price, the rest is refunded.
+	if acc := sm.GetAccount(store, alice); !acc.Balance.IsEqual(types.Coins{{"", 97}}) {
+		t.Errorf("Expected refund of 97, got %v", acc.Balance)
+	}
+
+	// Bob's key of the same name is his own, and leaves alice's alone.
+	res = plugintest.RunTx(ep, store, bob, types.Coins{{"", 100}}, TxBytes(&SetTx{Key: []byte("k"), Value: []byte("v2")}))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error setting key: %v", res)
+	}
+	res = plugintest.RunTx(ep, store, bob, types.Coins{{"", 1}}, TxBytes(&GetTx{Owner: alice, Key: []byte("k")}))
+	if res.IsErr() || !bytes.Equal(res.Data, []byte("v1")) {
+		t.Fatalf("Expected to get v1, got %v", res)
+	}
+
+	res = plugintest.RunTx(ep, store, alice, types.Coins{{"", 1}}, TxBytes(&RemoveTx{Key: []byte("k")}))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error removing key: %v", res)
+	}
+	if ep.GetEntry(store, alice, []byte("k")) != nil {
+		t.Fatal("Expected alice's key to be removed")
+	}
+	sm.IterateKeys(store, func(key []byte, value []byte) bool {
+		if bytes.Equal(key, ep.EntryKey(alice, []byte("k"))) {
+			t.Error("Expected alice's key to be removed from the index")
+		}
+		return true
+	})
+	if ep.GetEntry(store, bob, []byte("k")) == nil {
+		t.Fatal("Expected bob's key to be kept")
+	}
+	res = plugintest.RunTx(ep, store, alice, types.Coins{{"", 1}}, TxBytes(&RemoveTx{Key: []byte("k")}))
+	if res.IsOK() {
+		t.Fatal("Expected removing a missing key to fail")
+	}
+}
+
+func TestEyesPrice(t *testing.T) {
+	store := types.NewMemKVStore()
+	ep := New("eyes")
+	alice := []byte("alice_address_000000")
+
+	if log := ep.SetOption(store, "price", "10"); log != "Success" {
+		t.Fatalf("Failed to set price: %v", log)
+	}
+	res := plugintest.RunTx(ep, store, alice, types.Coins{{"", 20}}, TxBytes(&SetTx{Key: []byte("k"), Value: []byte("v1")}))
+	if res.IsOK() {
+		t.Fatal("Expected 3 bytes at price 10 to cost more than 20")
+	}
+}
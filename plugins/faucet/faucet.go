@@ -0,0 +1,229 @@
+package faucet
+
+import (
+	"strconv"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+const (
+	defaultAmount = 100
+	defaultPeriod = 100
+)
+
+/*
+Tx is the data of an AppTx sent to the faucet plugin.
+
+  - ClaimTx       Get coins from the faucet
+*/
+type Tx interface {
+	AssertIsTx()
+}
+
+const (
+	TxTypeClaim = byte(0x01)
+)
+
+func (_ *ClaimTx) AssertIsTx() {}
+
+var _ = wire.RegisterInterface(
+	struct{ Tx }{},
+	wire.ConcreteType{&ClaimTx{}, TxTypeClaim},
+)
+
+// Encodes tx as the data of an AppTx.
+func TxBytes(tx Tx) []byte {
+	return wire.BinaryBytes(struct{ Tx }{tx})
+}
+
+type ClaimTx struct {
+}
+
+//----------------------------------------
+
+type FaucetPlugin struct {
+	name   string
+	height uint64
+}
+
+func New(name string) *FaucetPlugin {
+	return &FaucetPlugin{
+		name: name,
+	}
+}
+
+func (fp *FaucetPlugin) Name() string {
+	return fp.name
+}
+
+// Holds the height of the last claim by addr.
+func (fp *FaucetPlugin) ClaimKey(addr []byte) []byte {
+	return append([]byte(fp.name+"/c/"), addr...)
+}
+
+func (fp *FaucetPlugin) amountKey() []byte {
+	return []byte(fp.name + "/amount")
+}
+
+func (fp *FaucetPlugin) periodKey() []byte {
+	return []byte(fp.name + "/period")
+}
+
+func (fp *FaucetPlugin) disabledKey() []byte {
+	return []byte(fp.name + "/disabled")
+}
+
+// Returns the coins given out per claim.
+func (fp *FaucetPlugin) GetAmount(store types.KVStore) types.Coins {
+	data := store.Get(fp.amountKey())
+	if len(data) == 0 {
+		return types.Coins{{"", defaultAmount}}
+	}
+	var amount types.Coins
+	fp.readBinary(data, &amount)
+	return amount
+}
+
+// Returns the number of blocks an address must wait between claims.
+func (fp *FaucetPlugin) GetPeriod(store types.KVStore) uint64 {
+	data := store.Get(fp.periodKey())
+	if len(data) == 0 {
+		return defaultPeriod
+	}
+	var period uint64
+	fp.readBinary(data, &period)
+	return period
+}
+
+func (fp *FaucetPlugin) IsEnabled(store types.KVStore) bool {
+	return len(store.Get(fp.disabledKey())) == 0
+}
+
+// Returns the height of the last claim by addr, and whether there was one.
+func (fp *FaucetPlugin) GetLastClaim(store types.KVStore, addr []byte) (uint64, bool) {
+	data := store.Get(fp.ClaimKey(addr))
+	if len(data) == 0 {
+		return 0, false
+	}
+	var height uint64
+	fp.readBinary(data, &height)
+	return height, true
+}
+
+// The "fund" option creates coins in the faucet, so it's refused
+// after genesis. Use "enabled" with "false" to turn it off.
+func (fp *FaucetPlugin) SetOption(store types.KVStore, key string, value string) (log string) {
+	switch key {
+	case "fund", "amount":
+		if key == "fund" && sm.GetLastHeight(store) > 0 {
+			return "Cannot fund the faucet after genesis"
+		}
+		var err error
+		var coins types.Coins
+		wire.ReadJSONPtr(&coins, []byte(value), &err)
+		if err != nil {
+			return "Error decoding coins: " + err.Error()
+		}
+		if !coins.IsValid() {
+			return "Invalid coins " + value
+		}
+		if key == "fund" {
+			sm.MintCoins(store, types.PluginAddress(fp.name), coins)
+		} else {
+			store.Set(fp.amountKey(), wire.BinaryBytes(coins))
+		}
+		return "Success"
+	case "period":
+		period, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return "Invalid period " + value
+		}
+		store.Set(fp.periodKey(), wire.BinaryBytes(period))
+		return "Success"
+	case "enabled":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return "Invalid enabled " + value
+		}
+		if enabled {
+			store.Set(fp.disabledKey(), nil)
+		} else {
+			store.Set(fp.disabledKey(), []byte{0x01})
+		}
+		return "Success"
+	}
+	return "Unrecognized option key " + key
+}
+
+func (fp *FaucetPlugin) CheckTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	_, res = fp.validateTx(store, ctx, txBytes)
+	return res
+}
+
+func (fp *FaucetPlugin) RunTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	_, res = fp.validateTx(store, ctx, txBytes)
+	if res.IsErr() {
+		return res
+	}
+
+	store.Set(fp.ClaimKey(ctx.Caller), wire.BinaryBytes(fp.height))
+	// Give back the call coins along with the claim.
+	payout := ctx.Coins.Plus(fp.GetAmount(store))
+	if !sm.TransferCoins(store, types.PluginAddress(fp.name), ctx.Caller, payout) {
+		PanicSanity(Fmt("Plugin account should hold %v", payout))
+	}
+	return tmsp.OK
+}
+
+func (fp *FaucetPlugin) InitChain(store types.KVStore, vals []*tmsp.Validator) {
+}
+
+func (fp *FaucetPlugin) BeginBlock(store types.KVStore, height uint64) {
+	fp.height = height
+}
+
+func (fp *FaucetPlugin) EndBlock(store types.KVStore, height uint64) []*tmsp.Validator {
+	return nil
+}
+
+//----------------------------------------
+
+// Decodes the tx and checks that the caller may claim.
+func (fp *FaucetPlugin) validateTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (Tx, tmsp.Result) {
+	var tx Tx
+	err := wire.ReadBinaryBytes(txBytes, &tx)
+	if err != nil {
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Error decoding tx: " + err.Error())
+	}
+
+	switch tx.(type) {
+	case *ClaimTx:
+		if !fp.IsEnabled(store) {
+			return nil, tmsp.ErrUnauthorized.AppendLog("Faucet is disabled")
+		}
+		if last, ok := fp.GetLastClaim(store, ctx.Caller); ok {
+			if next := last + fp.GetPeriod(store); fp.height < next {
+				return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Cannot claim again until height %v", next))
+			}
+		}
+		amount := fp.GetAmount(store)
+		acc := sm.GetAccount(store, types.PluginAddress(fp.name))
+		if acc == nil || !acc.Balance.IsGTE(ctx.Coins.Plus(amount)) {
+			return nil, tmsp.ErrBaseInsufficientFunds.AppendLog(Fmt("Faucet cannot pay out %v", amount))
+		}
+	default:
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Unknown tx type")
+	}
+	return tx, tmsp.OK
+}
+
+func (fp *FaucetPlugin) readBinary(data []byte, ptr interface{}) {
+	err := wire.ReadBinaryBytes(data, ptr)
+	if err != nil {
+		panic(Fmt("Error reading %v state %X error: %v", fp.name, data, err.Error()))
+	}
+}
@@ -0,0 +1,54 @@
+package faucet
+
+import (
+	"testing"
+
+	"github.com/tendermint/basecoin/plugins/plugintest"
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+)
+
+func TestFaucetRateLimit(t *testing.T) {
+	store := types.NewMemKVStore()
+	fp := New("faucet")
+	if log := fp.SetOption(store, "fund", `[{"denom":"","amount":25}]`); log != "Success" {
+		t.Fatalf("Unexpected log funding faucet: %v", log)
+	}
+	fp.SetOption(store, "amount", `[{"denom":"","amount":10}]`)
+	fp.SetOption(store, "period", "5")
+	caller := []byte("caller_address_00000")
+
+	fp.BeginBlock(store, 1)
+	if res := plugintest.RunTx(fp, store, caller, nil, TxBytes(&ClaimTx{})); res.IsErr() {
+		t.Fatalf("Unexpected error claiming: %v", res)
+	}
+	fp.BeginBlock(store, 5)
+	if res := plugintest.RunTx(fp, store, caller, nil, TxBytes(&ClaimTx{})); res.IsOK() {
+		t.Fatal("Expected a claim within the period to be rejected")
+	}
+	fp.BeginBlock(store, 6)
+	if res := plugintest.RunTx(fp, store, caller, nil, TxBytes(&ClaimTx{})); res.IsErr() {
+		t.Fatalf("Unexpected error claiming: %v", res)
+	}
+	if acc := sm.GetAccount(store, caller); !acc.Balance.IsEqual(types.Coins{{"", 20}}) {
+		t.Errorf("Expected two claims of 10, got %v", acc.Balance)
+	}
+
+	fp.SetOption(store, "enabled", "false")
+	fp.BeginBlock(store, 20)
+	if res := plugintest.RunTx(fp, store, []byte("other_address_000000"), nil, TxBytes(&ClaimTx{})); res.IsOK() {
+		t.Fatal("Expected a claim from a disabled faucet to be rejected")
+	}
+}
+
+func TestFaucetFundOnlyAtGenesis(t *testing.T) {
+	store := types.NewMemKVStore()
+	fp := New("faucet")
+	sm.SetLastHeight(store, 1)
+	if log := fp.SetOption(store, "fund", `[{"denom":"","amount":25}]`); log == "Success" {
+		t.Fatal("Expected funding after genesis to be refused")
+	}
+	if supply := sm.GetSupply(store, ""); supply != 0 {
+		t.Errorf("Expected no coins to be minted, got %v", supply)
+	}
+}
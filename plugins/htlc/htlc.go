@@ -0,0 +1,262 @@
+package htlc
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+/*
+Tx is the data of an AppTx sent to the htlc plugin.
+
+  - LockTx        Lock the call coins under a hashlock until a timeout height
+  - ClaimTx       Pay a contract to its recipient by revealing the preimage
+  - ReclaimTx     Return a contract to its sender once it has timed out
+*/
+type Tx interface {
+	AssertIsTx()
+}
+
+const (
+	TxTypeLock    = byte(0x01)
+	TxTypeClaim   = byte(0x02)
+	TxTypeReclaim = byte(0x03)
+)
+
+func (_ *LockTx) AssertIsTx()    {}
+func (_ *ClaimTx) AssertIsTx()   {}
+func (_ *ReclaimTx) AssertIsTx() {}
+
+var _ = wire.RegisterInterface(
+	struct{ Tx }{},
+	wire.ConcreteType{&LockTx{}, TxTypeLock},
+	wire.ConcreteType{&ClaimTx{}, TxTypeClaim},
+	wire.ConcreteType{&ReclaimTx{}, TxTypeReclaim},
+)
+
+// Encodes tx as the data of an AppTx.
+func TxBytes(tx Tx) []byte {
+	return wire.BinaryBytes(struct{ Tx }{tx})
+}
+
+type LockTx struct {
+	Recipient []byte `json:"recipient"`
+	Hashlock  []byte `json:"hashlock"` // SHA256 of the preimage
+	Timeout   uint64 `json:"timeout"`  // Height from which the sender may reclaim
+}
+
+type ClaimTx struct {
+	Hashlock []byte `json:"hashlock"`
+	Preimage []byte `json:"preimage"`
+}
+
+type ReclaimTx struct {
+	Hashlock []byte `json:"hashlock"`
+}
+
+//----------------------------------------
+
+const (
+	StatusLocked    = byte(0x01)
+	StatusClaimed   = byte(0x02)
+	StatusReclaimed = byte(0x03)
+)
+
+// A Contract is identified by its hashlock, which can only be used once.
+type Contract struct {
+	Hashlock  []byte      `json:"hashlock"`
+	Sender    []byte      `json:"sender"`
+	Recipient []byte      `json:"recipient"`
+	Coins     types.Coins `json:"coins"`
+	Timeout   uint64      `json:"timeout"`
+	Preimage  []byte      `json:"preimage"` // Set once claimed
+	Status    byte        `json:"status"`
+}
+
+//----------------------------------------
+
+type HTLCPlugin struct {
+	name   string
+	height uint64
+}
+
+func New(name string) *HTLCPlugin {
+	return &HTLCPlugin{
+		name: name,
+	}
+}
+
+func (hp *HTLCPlugin) Name() string {
+	return hp.name
+}
+
+func (hp *HTLCPlugin) ContractKey(hashlock []byte) []byte {
+	return append([]byte(hp.name+"/c/"), hashlock...)
+}
+
+func (hp *HTLCPlugin) GetContract(store types.KVStore, hashlock []byte) *Contract {
+	data := store.Get(hp.ContractKey(hashlock))
+	if len(data) == 0 {
+		return nil
+	}
+	var contract *Contract
+	err := wire.ReadBinaryBytes(data, &contract)
+	if err != nil {
+		panic(Fmt("Error reading contract %X error: %v", data, err.Error()))
+	}
+	return contract
+}
+
+func (hp *HTLCPlugin) setContract(store types.KVStore, contract *Contract) {
+	store.Set(hp.ContractKey(contract.Hashlock), wire.BinaryBytes(contract))
+}
+
+func (hp *HTLCPlugin) SetOption(store types.KVStore, key string, value string) (log string) {
+	return "Unrecognized option key " + key
+}
+
+func (hp *HTLCPlugin) CheckTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	_, res = hp.validateTx(store, ctx, txBytes)
+	return res
+}
+
+func (hp *HTLCPlugin) RunTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	tx, res := hp.validateTx(store, ctx, txBytes)
+	if res.IsErr() {
+		return res
+	}
+
+	switch tx := tx.(type) {
+	case *LockTx:
+		hp.setContract(store, &Contract{
+			Hashlock:  tx.Hashlock,
+			Sender:    ctx.Caller,
+			Recipient: tx.Recipient,
+			Coins:     ctx.Coins,
+			Timeout:   tx.Timeout,
+			Status:    StatusLocked,
+		})
+		// The call coins are held until the contract is claimed or reclaimed.
+		return tmsp.OK
+	case *ClaimTx:
+		contract := hp.GetContract(store, tx.Hashlock)
+		hp.pay(store, contract.Recipient, contract.Coins)
+		contract.Preimage = tx.Preimage
+		contract.Status = StatusClaimed
+		hp.setContract(store, contract)
+	case *ReclaimTx:
+		contract := hp.GetContract(store, tx.Hashlock)
+		hp.pay(store, contract.Sender, contract.Coins)
+		contract.Status = StatusReclaimed
+		hp.setContract(store, contract)
+	}
+
+	// Claims and reclaims pay out of the contract; their call coins go back.
+	hp.pay(store, ctx.Caller, ctx.Coins)
+	return tmsp.OK
+}
+
+// Query is a hashlock. The contract includes the preimage once claimed.
+func (hp *HTLCPlugin) Query(store types.KVStore, query []byte) (res tmsp.Result) {
+	contract := hp.GetContract(store, query)
+	if contract == nil {
+		return tmsp.ErrUnknownRequest.AppendLog(Fmt("Contract %X not found", query))
+	}
+	return tmsp.NewResultOK(wire.BinaryBytes(contract), "")
+}
+
+func (hp *HTLCPlugin) InitChain(store types.KVStore, vals []*tmsp.Validator) {
+}
+
+func (hp *HTLCPlugin) BeginBlock(store types.KVStore, height uint64) {
+	hp.height = height
+}
+
+func (hp *HTLCPlugin) EndBlock(store types.KVStore, height uint64) []*tmsp.Validator {
+	return nil
+}
+
+//----------------------------------------
+
+// Decodes the tx and checks it against the contract: a lock needs a
+// fresh SHA256 hashlock, a claim the preimage before the timeout, and
+// a reclaim the sender after it.
+func (hp *HTLCPlugin) validateTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (Tx, tmsp.Result) {
+	var tx Tx
+	err := wire.ReadBinaryBytes(txBytes, &tx)
+	if err != nil {
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Error decoding tx: " + err.Error())
+	}
+
+	switch tx := tx.(type) {
+	case *LockTx:
+		if len(tx.Recipient) != 20 {
+			return nil, tmsp.ErrBaseInvalidOutput.AppendLog("Invalid recipient address length")
+		}
+		if len(tx.Hashlock) != sha256.Size {
+			return nil, tmsp.ErrEncodingError.AppendLog("Hashlock must be a SHA256 hash")
+		}
+		if hp.GetContract(store, tx.Hashlock) != nil {
+			return nil, tmsp.ErrEncodingError.AppendLog(Fmt("Hashlock %X was already used", tx.Hashlock))
+		}
+		if tx.Timeout <= hp.height {
+			return nil, tmsp.ErrEncodingError.AppendLog(
+				Fmt("Timeout %v must be after the current height %v", tx.Timeout, hp.height))
+		}
+		if !ctx.Coins.IsPositive() {
+			return nil, tmsp.ErrBaseInsufficientFunds.AppendLog("Contract must lock some coins")
+		}
+	case *ClaimTx:
+		contract, res := hp.getLockedContract(store, tx.Hashlock)
+		if res.IsErr() {
+			return nil, res
+		}
+		if !bytes.Equal(ctx.Caller, contract.Recipient) {
+			return nil, tmsp.ErrUnauthorized.AppendLog("Only the recipient may claim a contract")
+		}
+		if hp.height >= contract.Timeout {
+			return nil, tmsp.ErrUnauthorized.AppendLog("Contract has timed out")
+		}
+		hash := sha256.Sum256(tx.Preimage)
+		if !bytes.Equal(hash[:], contract.Hashlock) {
+			return nil, tmsp.ErrUnauthorized.AppendLog("Preimage does not match hashlock")
+		}
+	case *ReclaimTx:
+		contract, res := hp.getLockedContract(store, tx.Hashlock)
+		if res.IsErr() {
+			return nil, res
+		}
+		if !bytes.Equal(ctx.Caller, contract.Sender) {
+			return nil, tmsp.ErrUnauthorized.AppendLog("Only the sender may reclaim a contract")
+		}
+		if hp.height < contract.Timeout {
+			return nil, tmsp.ErrUnauthorized.AppendLog(
+				Fmt("Contract cannot be reclaimed before height %v", contract.Timeout))
+		}
+	default:
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Unknown tx type")
+	}
+	return tx, tmsp.OK
+}
+
+func (hp *HTLCPlugin) getLockedContract(store types.KVStore, hashlock []byte) (*Contract, tmsp.Result) {
+	contract := hp.GetContract(store, hashlock)
+	if contract == nil {
+		return nil, tmsp.ErrUnknownRequest.AppendLog(Fmt("Contract %X not found", hashlock))
+	}
+	if contract.Status != StatusLocked {
+		return nil, tmsp.ErrUnknownRequest.AppendLog(Fmt("Contract %X is already settled", hashlock))
+	}
+	return contract, tmsp.OK
+}
+
+func (hp *HTLCPlugin) pay(store types.KVStore, addr []byte, coins types.Coins) {
+	if !sm.TransferCoins(store, types.PluginAddress(hp.name), addr, coins) {
+		PanicSanity(Fmt("Plugin account should hold %v", coins))
+	}
+}
@@ -0,0 +1,82 @@
+package htlc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/tendermint/basecoin/plugins/plugintest"
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	"github.com/tendermint/go-wire"
+)
+
+var (
+	sender    = []byte("sender_address_00000")
+	recipient = []byte("recipient_address_00")
+)
+
+func TestHTLCClaim(t *testing.T) {
+	store := types.NewMemKVStore()
+	hp := New("htlc")
+	hp.BeginBlock(store, 1)
+
+	preimage := []byte("secret")
+	hash := sha256.Sum256(preimage)
+	res := plugintest.RunTx(hp, store, sender, types.Coins{{"", 100}}, TxBytes(&LockTx{
+		Recipient: recipient,
+		Hashlock:  hash[:],
+		Timeout:   10,
+	}))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error locking coins: %v", res)
+	}
+
+	res = plugintest.RunTx(hp, store, recipient, types.Coins{{"", 1}}, TxBytes(&ClaimTx{Hashlock: hash[:], Preimage: []byte("wrong")}))
+	if res.IsOK() {
+		t.Fatal("Expected claim with the wrong preimage to fail")
+	}
+	res = plugintest.RunTx(hp, store, sender, types.Coins{{"", 1}}, TxBytes(&ReclaimTx{Hashlock: hash[:]}))
+	if res.IsOK() {
+		t.Fatal("Expected reclaim before the timeout to fail")
+	}
+	res = plugintest.RunTx(hp, store, recipient, types.Coins{{"", 1}}, TxBytes(&ClaimTx{Hashlock: hash[:], Preimage: preimage}))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error claiming: %v", res)
+	}
+
+	res = hp.Query(store, hash[:])
+	var contract *Contract
+	if err := wire.ReadBinaryBytes(res.Data, &contract); err != nil {
+		t.Fatalf("Unexpected query result %v: %v", res, err)
+	}
+	if contract == nil || contract.Status != StatusClaimed || !bytes.Equal(contract.Preimage, preimage) {
+		t.Errorf("Expected claimed contract with preimage, got %v", contract)
+	}
+}
+
+func TestHTLCReclaim(t *testing.T) {
+	store := types.NewMemKVStore()
+	hp := New("htlc")
+	hp.BeginBlock(store, 1)
+
+	hash := sha256.Sum256([]byte("secret"))
+	plugintest.RunTx(hp, store, sender, types.Coins{{"", 100}}, TxBytes(&LockTx{
+		Recipient: recipient,
+		Hashlock:  hash[:],
+		Timeout:   10,
+	}))
+
+	hp.BeginBlock(store, 10)
+	res := plugintest.RunTx(hp, store, recipient, types.Coins{{"", 1}}, TxBytes(&ClaimTx{Hashlock: hash[:], Preimage: []byte("secret")}))
+	if res.IsOK() {
+		t.Fatal("Expected claim after the timeout to fail")
+	}
+	res = plugintest.RunTx(hp, store, sender, types.Coins{{"", 1}}, TxBytes(&ReclaimTx{Hashlock: hash[:]}))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error reclaiming: %v", res)
+	}
+	if acc := sm.GetAccount(store, sender); !acc.Balance.IsEqual(types.Coins{{"", 101}}) {
+		t.Errorf("Expected sender to get 101 back, got %v", acc.Balance)
+	}
+}
@@ -0,0 +1,273 @@
+package issue
+
+import (
+	"bytes"
+	"math"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+const (
+	maxDenomLength = 16
+)
+
+/*
+Tx is the data of an AppTx sent to the issue plugin.
+
+  - CreateTx      Create a new denom owned by the caller
+  - MintTx        Create coins of a denom, only allowed for its owner
+  - BurnTx        Destroy coins of a denom sent along with the tx
+*/
+type Tx interface {
+	AssertIsTx()
+}
+
+const (
+	TxTypeCreate = byte(0x01)
+	TxTypeMint   = byte(0x02)
+	TxTypeBurn   = byte(0x03)
+)
+
+func (_ *CreateTx) AssertIsTx() {}
+func (_ *MintTx) AssertIsTx()   {}
+func (_ *BurnTx) AssertIsTx()   {}
+
+var _ = wire.RegisterInterface(
+	struct{ Tx }{},
+	wire.ConcreteType{&CreateTx{}, TxTypeCreate},
+	wire.ConcreteType{&MintTx{}, TxTypeMint},
+	wire.ConcreteType{&BurnTx{}, TxTypeBurn},
+)
+
+// Encodes tx as the data of an AppTx.
+func TxBytes(tx Tx) []byte {
+	return wire.BinaryBytes(struct{ Tx }{tx})
+}
+
+type CreateTx struct {
+	Denom string `json:"denom"`
+	Cap   int64  `json:"cap"` // Maximum supply, or 0 for no cap
+}
+
+type MintTx struct {
+	Denom  string `json:"denom"`
+	To     []byte `json:"to"`
+	Amount int64  `json:"amount"`
+}
+
+type BurnTx struct {
+	Denom  string `json:"denom"`
+	Amount int64  `json:"amount"`
+}
+
+//----------------------------------------
+
+// The supply of a denom isn't kept here, but in state. See sm.GetSupply().
+type Denom struct {
+	Name  string `json:"name"`
+	Owner []byte `json:"owner"`
+	Cap   int64  `json:"cap"`
+}
+
+// DenomSupply is the answer to a query.
+type DenomSupply struct {
+	Denom  *Denom `json:"denom"`
+	Supply int64  `json:"supply"`
+}
+
+//----------------------------------------
+
+type IssuePlugin struct {
+	name string
+}
+
+func New(name string) *IssuePlugin {
+	return &IssuePlugin{
+		name: name,
+	}
+}
+
+func (ip *IssuePlugin) Name() string {
+	return ip.name
+}
+
+func (ip *IssuePlugin) DenomKey(denom string) []byte {
+	return []byte(ip.name + "/d/" + denom)
+}
+
+func (ip *IssuePlugin) GetDenom(store types.KVStore, denom string) *Denom {
+	data := store.Get(ip.DenomKey(denom))
+	if len(data) == 0 {
+		return nil
+	}
+	var d *Denom
+	err := wire.ReadBinaryBytes(data, &d)
+	if err != nil {
+		panic(Fmt("Error reading denom %X error: %v", data, err.Error()))
+	}
+	return d
+}
+
+func (ip *IssuePlugin) setDenom(store types.KVStore, d *Denom) {
+	store.Set(ip.DenomKey(d.Name), wire.BinaryBytes(d))
+}
+
+// The "reserve" option keeps a denom that exists at genesis from being
+// created, and hence minted, by anyone.
+func (ip *IssuePlugin) SetOption(store types.KVStore, key string, value string) (log string) {
+	switch key {
+	case "reserve":
+		if ip.GetDenom(store, value) != nil {
+			return "Denom already exists: " + value
+		}
+		ip.setDenom(store, &Denom{Name: value})
+		return "Success"
+	}
+	return "Unrecognized option key " + key
+}
+
+func (ip *IssuePlugin) CheckTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	_, res = ip.validateTx(store, ctx, txBytes)
+	return res
+}
+
+func (ip *IssuePlugin) RunTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	tx, res := ip.validateTx(store, ctx, txBytes)
+	if res.IsErr() {
+		return res
+	}
+
+	var burned types.Coins
+	switch tx := tx.(type) {
+	case *CreateTx:
+		ip.setDenom(store, &Denom{
+			Name:  tx.Denom,
+			Owner: ctx.Caller,
+			Cap:   tx.Cap,
+		})
+	case *MintTx:
+		sm.MintCoins(store, tx.To, types.Coins{{tx.Denom, tx.Amount}})
+	case *BurnTx:
+		burned = types.Coins{{tx.Denom, tx.Amount}}
+		if !sm.BurnCoins(store, types.PluginAddress(ip.name), burned) {
+			PanicSanity(Fmt("Plugin account should hold %v", burned))
+		}
+	}
+
+	// Give back whatever wasn't burned.
+	refund := ctx.Coins.Minus(burned)
+	if !sm.TransferCoins(store, types.PluginAddress(ip.name), ctx.Caller, refund) {
+		PanicSanity(Fmt("Plugin account should hold %v", refund))
+	}
+	return tmsp.OK
+}
+
+// Query is the name of a denom.
+func (ip *IssuePlugin) Query(store types.KVStore, query []byte) (res tmsp.Result) {
+	d := ip.GetDenom(store, string(query))
+	if d == nil {
+		return tmsp.ErrUnknownRequest.AppendLog(Fmt("Denom %v not found", string(query)))
+	}
+	return tmsp.NewResultOK(wire.BinaryBytes(DenomSupply{d, sm.GetSupply(store, d.Name)}), "")
+}
+
+func (ip *IssuePlugin) InitChain(store types.KVStore, vals []*tmsp.Validator) {
+}
+
+func (ip *IssuePlugin) BeginBlock(store types.KVStore, height uint64) {
+}
+
+func (ip *IssuePlugin) EndBlock(store types.KVStore, height uint64) []*tmsp.Validator {
+	return nil
+}
+
+//----------------------------------------
+
+// Decodes the tx and checks that a new denom isn't in use, and that
+// mints stay under the cap and burns send the coins along.
+func (ip *IssuePlugin) validateTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (Tx, tmsp.Result) {
+	var tx Tx
+	err := wire.ReadBinaryBytes(txBytes, &tx)
+	if err != nil {
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Error decoding tx: " + err.Error())
+	}
+
+	switch tx := tx.(type) {
+	case *CreateTx:
+		if !isValidDenom(tx.Denom) {
+			return nil, tmsp.ErrEncodingError.AppendLog(Fmt("Invalid denom %v", tx.Denom))
+		}
+		if tx.Cap < 0 {
+			return nil, tmsp.ErrEncodingError.AppendLog("Cap cannot be negative")
+		}
+		if ip.GetDenom(store, tx.Denom) != nil {
+			return nil, tmsp.ErrEncodingError.AppendLog(Fmt("Denom %v already exists", tx.Denom))
+		}
+		// Coins of a denom nobody created can still circulate from genesis.
+		if sm.GetSupply(store, tx.Denom) != 0 {
+			return nil, tmsp.ErrEncodingError.AppendLog(Fmt("Denom %v is already in circulation", tx.Denom))
+		}
+	case *MintTx:
+		d, res := ip.getIssuedDenom(store, tx.Denom)
+		if res.IsErr() {
+			return nil, res
+		}
+		if !bytes.Equal(ctx.Caller, d.Owner) {
+			return nil, tmsp.ErrUnauthorized.AppendLog("Only the owner may mint " + tx.Denom)
+		}
+		if len(tx.To) != 20 {
+			return nil, tmsp.ErrBaseInvalidOutput.AppendLog("Invalid address length")
+		}
+		if tx.Amount <= 0 {
+			return nil, tmsp.ErrEncodingError.AppendLog("Amount must be positive")
+		}
+		supply := sm.GetSupply(store, tx.Denom)
+		if tx.Amount > math.MaxInt64-supply || (d.Cap != 0 && supply+tx.Amount > d.Cap) {
+			return nil, tmsp.ErrUnauthorized.AppendLog(
+				Fmt("Minting %v would exceed the cap of %v %v", tx.Amount, d.Cap, tx.Denom))
+		}
+	case *BurnTx:
+		if _, res := ip.getIssuedDenom(store, tx.Denom); res.IsErr() {
+			return nil, res
+		}
+		if tx.Amount <= 0 {
+			return nil, tmsp.ErrEncodingError.AppendLog("Amount must be positive")
+		}
+		if !ctx.Coins.IsGTE(types.Coins{{tx.Denom, tx.Amount}}) {
+			return nil, tmsp.ErrBaseInsufficientFunds.AppendLog(
+				Fmt("Must send %v %v along to burn them", tx.Amount, tx.Denom))
+		}
+	default:
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Unknown tx type")
+	}
+	return tx, tmsp.OK
+}
+
+func (ip *IssuePlugin) getIssuedDenom(store types.KVStore, denom string) (*Denom, tmsp.Result) {
+	d := ip.GetDenom(store, denom)
+	if d == nil {
+		return nil, tmsp.ErrUnknownRequest.AppendLog(Fmt("Denom %v not found", denom))
+	}
+	if len(d.Owner) == 0 {
+		return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Denom %v is reserved", denom))
+	}
+	return d, tmsp.OK
+}
+
+// Denoms are short and alphanumeric, so they can't be confused
+// with the default coin.
+func isValidDenom(denom string) bool {
+	if len(denom) == 0 || len(denom) > maxDenomLength {
+		return false
+	}
+	for _, c := range denom {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package issue
+
+import (
+	"testing"
+
+	"github.com/tendermint/basecoin/plugins/plugintest"
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+)
+
+var (
+	owner  = []byte("owner_address_000000")
+	holder = []byte("holder_address_00000")
+)
+
+func TestIssueMintAndBurn(t *testing.T) {
+	store := types.NewMemKVStore()
+	ip := New("issue")
+
+	res := plugintest.RunTx(ip, store, owner, nil, TxBytes(&CreateTx{Denom: "gold", Cap: 100}))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error creating denom: %v", res)
+	}
+	if res := plugintest.RunTx(ip, store, holder, nil, TxBytes(&CreateTx{Denom: "gold"})); res.IsOK() {
+		t.Fatal("Expected creating an existing denom to fail")
+	}
+
+	if res := plugintest.RunTx(ip, store, holder, nil, TxBytes(&MintTx{"gold", holder, 10})); res.IsOK() {
+		t.Fatal("Expected minting by someone other than the owner to fail")
+	}
+	if res := plugintest.RunTx(ip, store, owner, nil, TxBytes(&MintTx{"gold", holder, 60})); res.IsErr() {
+		t.Fatalf("Unexpected error minting: %v", res)
+	}
+	if res := plugintest.RunTx(ip, store, owner, nil, TxBytes(&MintTx{"gold", holder, 41})); res.IsOK() {
+		t.Fatal("Expected minting past the cap to fail")
+	}
+	if supply := sm.GetSupply(store, "gold"); supply != 60 {
+		t.Errorf("Expected supply of 60, got %v", supply)
+	}
+
+	// The holder sends 25 gold along and burns 20 of it.
+	sm.SubtractCoins(store, holder, types.Coins{{"gold", 25}})
+	res = plugintest.RunTx(ip, store, holder, types.Coins{{"gold", 25}}, TxBytes(&BurnTx{"gold", 20}))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error burning: %v", res)
+	}
+	if acc := sm.GetAccount(store, holder); !acc.Balance.IsEqual(types.Coins{{"gold", 40}}) {
+		t.Errorf("Expected 40 gold left after the burn, got %v", acc.Balance)
+	}
+	if supply := sm.GetSupply(store, "gold"); supply != 40 {
+		t.Errorf("Expected supply of 40, got %v", supply)
+	}
+
+	// Burning makes room under the cap.
+	if res := plugintest.RunTx(ip, store, owner, nil, TxBytes(&MintTx{"gold", holder, 60})); res.IsErr() {
+		t.Fatalf("Unexpected error minting up to the cap: %v", res)
+	}
+}
+
+func TestIssueRefusesCirculatingDenom(t *testing.T) {
+	store := types.NewMemKVStore()
+	ip := New("issue")
+
+	// Silver exists from genesis, without going through the plugin.
+	sm.MintCoins(store, holder, types.Coins{{"silver", 1000}})
+
+	if res := plugintest.RunTx(ip, store, owner, nil, TxBytes(&CreateTx{Denom: "silver"})); res.IsOK() {
+		t.Fatal("Expected creating a denom in circulation to fail")
+	}
+	if ip.GetDenom(store, "silver") != nil {
+		t.Fatal("Expected no denom to be created")
+	}
+}
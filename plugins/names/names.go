@@ -0,0 +1,321 @@
+package names
+
+import (
+	"bytes"
+	"strconv"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+const (
+	defaultFee    = 10
+	defaultPeriod = 100000
+	maxNameLength = 32
+)
+
+/*
+Tx is the data of an AppTx sent to the names plugin.
+
+  - RegisterTx    Register a free or expired name to the caller
+  - RenewTx       Extend the registration of a name
+  - TransferTx    Give a name to another address
+
+SendTx outputs pay registered names through ResolveName.
+*/
+type Tx interface {
+	AssertIsTx()
+}
+
+const (
+	TxTypeRegister = byte(0x01)
+	TxTypeRenew    = byte(0x02)
+	TxTypeTransfer = byte(0x03)
+)
+
+func (_ *RegisterTx) AssertIsTx() {}
+func (_ *RenewTx) AssertIsTx()    {}
+func (_ *TransferTx) AssertIsTx() {}
+
+var _ = wire.RegisterInterface(
+	struct{ Tx }{},
+	wire.ConcreteType{&RegisterTx{}, TxTypeRegister},
+	wire.ConcreteType{&RenewTx{}, TxTypeRenew},
+	wire.ConcreteType{&TransferTx{}, TxTypeTransfer},
+)
+
+// Encodes tx as the data of an AppTx.
+func TxBytes(tx Tx) []byte {
+	return wire.BinaryBytes(struct{ Tx }{tx})
+}
+
+type RegisterTx struct {
+	Name string `json:"name"`
+}
+
+type RenewTx struct {
+	Name string `json:"name"`
+}
+
+type TransferTx struct {
+	Name string `json:"name"`
+	To   []byte `json:"to"`
+}
+
+//----------------------------------------
+
+type Record struct {
+	Name    string `json:"name"`
+	Owner   []byte `json:"owner"`
+	Expires uint64 `json:"expires"` // Height from which the name is free again
+}
+
+//----------------------------------------
+
+type NamesPlugin struct {
+	name   string
+	height uint64
+}
+
+func New(name string) *NamesPlugin {
+	return &NamesPlugin{
+		name: name,
+	}
+}
+
+func (np *NamesPlugin) Name() string {
+	return np.name
+}
+
+func (np *NamesPlugin) RecordKey(name string) []byte {
+	return []byte(np.name + "/r/" + name)
+}
+
+func (np *NamesPlugin) feeKey() []byte {
+	return []byte(np.name + "/fee")
+}
+
+func (np *NamesPlugin) periodKey() []byte {
+	return []byte(np.name + "/period")
+}
+
+// Returns the fee, in the default coin, to register or renew a name.
+func (np *NamesPlugin) GetFee(store types.KVStore) int64 {
+	data := store.Get(np.feeKey())
+	if len(data) == 0 {
+		return defaultFee
+	}
+	var fee int64
+	np.readBinary(data, &fee)
+	return fee
+}
+
+// Returns the number of blocks a registration or renewal lasts.
+func (np *NamesPlugin) GetPeriod(store types.KVStore) uint64 {
+	data := store.Get(np.periodKey())
+	if len(data) == 0 {
+		return defaultPeriod
+	}
+	var period uint64
+	np.readBinary(data, &period)
+	return period
+}
+
+func (np *NamesPlugin) GetRecord(store types.KVStore, name string) *Record {
+	data := store.Get(np.RecordKey(name))
+	if len(data) == 0 {
+		return nil
+	}
+	var record *Record
+	np.readBinary(data, &record)
+	return record
+}
+
+func (np *NamesPlugin) setRecord(store types.KVStore, record *Record) {
+	store.Set(np.RecordKey(record.Name), wire.BinaryBytes(record))
+}
+
+func (np *NamesPlugin) SetOption(store types.KVStore, key string, value string) (log string) {
+	switch key {
+	case "fee":
+		fee, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || fee < 0 {
+			return "Invalid fee " + value
+		}
+		store.Set(np.feeKey(), wire.BinaryBytes(fee))
+		return "Success"
+	case "period":
+		period, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || period == 0 {
+			return "Invalid period " + value
+		}
+		store.Set(np.periodKey(), wire.BinaryBytes(period))
+		return "Success"
+	}
+	return "Unrecognized option key " + key
+}
+
+func (np *NamesPlugin) CheckTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	_, res = np.validateTx(store, ctx, txBytes)
+	return res
+}
+
+func (np *NamesPlugin) RunTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	tx, res := np.validateTx(store, ctx, txBytes)
+	if res.IsErr() {
+		return res
+	}
+
+	var fee types.Coins
+	switch tx := tx.(type) {
+	case *RegisterTx:
+		fee = np.fee(store)
+		np.setRecord(store, &Record{
+			Name:    tx.Name,
+			Owner:   ctx.Caller,
+			Expires: np.height + np.GetPeriod(store),
+		})
+	case *RenewTx:
+		fee = np.fee(store)
+		record := np.GetRecord(store, tx.Name)
+		record.Expires += np.GetPeriod(store)
+		np.setRecord(store, record)
+	case *TransferTx:
+		record := np.GetRecord(store, tx.Name)
+		record.Owner = tx.To
+		np.setRecord(store, record)
+	}
+
+	// Keep the fee and return the rest to the caller.
+	refund := ctx.Coins.Minus(fee)
+	if !sm.TransferCoins(store, types.PluginAddress(np.name), ctx.Caller, refund) {
+		PanicSanity(Fmt("Plugin account should hold %v", refund))
+	}
+	return tmsp.OK
+}
+
+// Query is a name.
+func (np *NamesPlugin) Query(store types.KVStore, query []byte) (res tmsp.Result) {
+	record := np.GetRecord(store, string(query))
+	if record == nil {
+		return tmsp.ErrUnknownRequest.AppendLog(Fmt("Name %v not found", string(query)))
+	}
+	return tmsp.NewResultOK(wire.BinaryBytes(record), "")
+}
+
+// Returns the owner of a registered name, or nil.
+// This makes NamesPlugin a types.NameResolver for SendTx outputs.
+func (np *NamesPlugin) ResolveName(store types.KVStore, name string) []byte {
+	record := np.GetRecord(store, name)
+	if record == nil || np.isExpired(record) {
+		return nil
+	}
+	return record.Owner
+}
+
+func (np *NamesPlugin) InitChain(store types.KVStore, vals []*tmsp.Validator) {
+}
+
+func (np *NamesPlugin) BeginBlock(store types.KVStore, height uint64) {
+	np.height = height
+}
+
+func (np *NamesPlugin) EndBlock(store types.KVStore, height uint64) []*tmsp.Validator {
+	return nil
+}
+
+//----------------------------------------
+
+// Decodes the tx and checks that the name is free to register, or
+// owned by the caller to renew or transfer, and that the fee is paid.
+func (np *NamesPlugin) validateTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (Tx, tmsp.Result) {
+	var tx Tx
+	err := wire.ReadBinaryBytes(txBytes, &tx)
+	if err != nil {
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Error decoding tx: " + err.Error())
+	}
+
+	switch tx := tx.(type) {
+	case *RegisterTx:
+		if !IsValidName(tx.Name) {
+			return nil, tmsp.ErrEncodingError.AppendLog(Fmt("Invalid name %v", tx.Name))
+		}
+		if record := np.GetRecord(store, tx.Name); record != nil && !np.isExpired(record) {
+			return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Name %v is taken", tx.Name))
+		}
+		if res := np.checkFee(store, ctx.Coins); res.IsErr() {
+			return nil, res
+		}
+	case *RenewTx:
+		if _, res := np.getOwnedRecord(store, ctx.Caller, tx.Name); res.IsErr() {
+			return nil, res
+		}
+		if res := np.checkFee(store, ctx.Coins); res.IsErr() {
+			return nil, res
+		}
+	case *TransferTx:
+		if _, res := np.getOwnedRecord(store, ctx.Caller, tx.Name); res.IsErr() {
+			return nil, res
+		}
+		if len(tx.To) != 20 {
+			return nil, tmsp.ErrBaseInvalidOutput.AppendLog("Invalid address length")
+		}
+	default:
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Unknown tx type")
+	}
+	return tx, tmsp.OK
+}
+
+// Returns the record of an unexpired name owned by caller.
+func (np *NamesPlugin) getOwnedRecord(store types.KVStore, caller []byte, name string) (*Record, tmsp.Result) {
+	record := np.GetRecord(store, name)
+	if record == nil || np.isExpired(record) {
+		return nil, tmsp.ErrUnknownRequest.AppendLog(Fmt("Name %v is not registered", name))
+	}
+	if !bytes.Equal(caller, record.Owner) {
+		return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Name %v is owned by %X", name, record.Owner))
+	}
+	return record, tmsp.OK
+}
+
+func (np *NamesPlugin) checkFee(store types.KVStore, coins types.Coins) tmsp.Result {
+	if fee := np.fee(store); !coins.IsGTE(fee) {
+		return tmsp.ErrBaseInsufficientFunds.AppendLog(Fmt("Fee is %v", fee))
+	}
+	return tmsp.OK
+}
+
+func (np *NamesPlugin) fee(store types.KVStore) types.Coins {
+	fee := np.GetFee(store)
+	if fee == 0 {
+		return nil
+	}
+	return types.Coins{{"", fee}}
+}
+
+func (np *NamesPlugin) isExpired(record *Record) bool {
+	return np.height >= record.Expires
+}
+
+func (np *NamesPlugin) readBinary(data []byte, ptr interface{}) {
+	err := wire.ReadBinaryBytes(data, ptr)
+	if err != nil {
+		panic(Fmt("Error reading %v state %X error: %v", np.name, data, err.Error()))
+	}
+}
+
+// Names are lowercase letters, digits, '-' and '.', up to 32 characters.
+func IsValidName(name string) bool {
+	if len(name) == 0 || len(name) > maxNameLength {
+		return false
+	}
+	for _, c := range name {
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package names
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tendermint/basecoin/plugins/plugintest"
+	"github.com/tendermint/basecoin/types"
+)
+
+var (
+	owner  = []byte("owner_address_000000")
+	buyer  = []byte("buyer_address_000000")
+	regFee = types.Coins{{"", 10}}
+)
+
+func TestNamesRegisterAndTransfer(t *testing.T) {
+	store := types.NewMemKVStore()
+	np := New("names")
+	np.SetOption(store, "period", "10")
+	np.BeginBlock(store, 1)
+
+	if res := plugintest.RunTx(np, store, owner, nil, TxBytes(&RegisterTx{"alice"})); res.IsOK() {
+		t.Fatal("Expected registering without the fee to fail")
+	}
+	if res := plugintest.RunTx(np, store, owner, regFee, TxBytes(&RegisterTx{"Alice!"})); res.IsOK() {
+		t.Fatal("Expected registering an invalid name to fail")
+	}
+	if res := plugintest.RunTx(np, store, owner, regFee, TxBytes(&RegisterTx{"alice"})); res.IsErr() {
+		t.Fatalf("Unexpected error registering: %v", res)
+	}
+	if res := plugintest.RunTx(np, store, buyer, regFee, TxBytes(&RegisterTx{"alice"})); res.IsOK() {
+		t.Fatal("Expected registering a taken name to fail")
+	}
+
+	if res := plugintest.RunTx(np, store, buyer, nil, TxBytes(&TransferTx{"alice", buyer})); res.IsOK() {
+		t.Fatal("Expected a transfer by someone other than the owner to fail")
+	}
+	if res := plugintest.RunTx(np, store, owner, nil, TxBytes(&TransferTx{"alice", buyer})); res.IsErr() {
+		t.Fatalf("Unexpected error transferring: %v", res)
+	}
+	if got := np.ResolveName(store, "alice"); !bytes.Equal(got, buyer) {
+		t.Errorf("Expected alice to resolve to the buyer, got %X", got)
+	}
+
+	// The name is free again once it expires.
+	np.BeginBlock(store, 11)
+	if got := np.ResolveName(store, "alice"); got != nil {
+		t.Errorf("Expected alice to have expired, got %X", got)
+	}
+	if res := plugintest.RunTx(np, store, owner, regFee, TxBytes(&RegisterTx{"alice"})); res.IsErr() {
+		t.Fatalf("Unexpected error registering an expired name: %v", res)
+	}
+}
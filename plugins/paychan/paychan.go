@@ -0,0 +1,350 @@
+package paychan
+
+import (
+	"bytes"
+	"strconv"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+const (
+	defaultDisputeWindow = 100
+)
+
+/*
+Tx is the data of an AppTx sent to the paychan plugin.
+
+  - OpenTx        Lock the call coins in a channel to a recipient
+  - CloseTx       Start closing a channel, or raise the amount it pays out
+*/
+type Tx interface {
+	AssertIsTx()
+}
+
+const (
+	TxTypeOpen  = byte(0x01)
+	TxTypeClose = byte(0x02)
+)
+
+func (_ *OpenTx) AssertIsTx()  {}
+func (_ *CloseTx) AssertIsTx() {}
+
+var _ = wire.RegisterInterface(
+	struct{ Tx }{},
+	wire.ConcreteType{&OpenTx{}, TxTypeOpen},
+	wire.ConcreteType{&CloseTx{}, TxTypeClose},
+)
+
+// Encodes tx as the data of an AppTx.
+func TxBytes(tx Tx) []byte {
+	return wire.BinaryBytes(struct{ Tx }{tx})
+}
+
+type OpenTx struct {
+	Recipient []byte `json:"recipient"`
+}
+
+// The recipient closes with the latest Payment from the sender.
+// The sender closes with no Payment.
+type CloseTx struct {
+	ChannelID uint64   `json:"channel_id"`
+	Payment   *Payment `json:"payment"`
+}
+
+//----------------------------------------
+
+// A Payment is signed off-chain by the sender, each one replacing the last.
+type Payment struct {
+	ChannelID uint64           `json:"channel_id"`
+	Amount    types.Coins      `json:"amount"` // Cumulative
+	Signature crypto.Signature `json:"signature"`
+}
+
+// Like a SendTx, a payment is signed for one chain only.
+func (p *Payment) SignBytes(chainID string) []byte {
+	signBytes := wire.BinaryBytes(chainID)
+	sig := p.Signature
+	p.Signature = nil
+	signBytes = append(signBytes, wire.BinaryBytes(p)...)
+	p.Signature = sig
+	return signBytes
+}
+
+//----------------------------------------
+
+type Channel struct {
+	ID           uint64        `json:"id"`
+	Sender       []byte        `json:"sender"`
+	SenderPubKey crypto.PubKey `json:"sender_pub_key"`
+	Recipient    []byte        `json:"recipient"`
+	Coins        types.Coins   `json:"coins"`
+	Paid         types.Coins   `json:"paid"`          // Owed to the recipient
+	SettleHeight uint64        `json:"settle_height"` // 0 until closing starts
+	Settled      bool          `json:"settled"`
+}
+
+func (ch *Channel) IsClosing() bool {
+	return ch.SettleHeight != 0
+}
+
+//----------------------------------------
+
+type PaychanPlugin struct {
+	name   string
+	height uint64
+}
+
+func New(name string) *PaychanPlugin {
+	return &PaychanPlugin{
+		name: name,
+	}
+}
+
+func (pp *PaychanPlugin) Name() string {
+	return pp.name
+}
+
+func (pp *PaychanPlugin) ChannelKey(id uint64) []byte {
+	return []byte(Fmt("%v/c/%v", pp.name, id))
+}
+
+func (pp *PaychanPlugin) settleKey(height uint64) []byte {
+	return []byte(Fmt("%v/s/%v", pp.name, height))
+}
+
+func (pp *PaychanPlugin) counterKey() []byte {
+	return []byte(pp.name + "/n")
+}
+
+func (pp *PaychanPlugin) disputeWindowKey() []byte {
+	return []byte(pp.name + "/dispute_window")
+}
+
+// Returns the number of blocks between a close and the settlement.
+func (pp *PaychanPlugin) GetDisputeWindow(store types.KVStore) uint64 {
+	data := store.Get(pp.disputeWindowKey())
+	if len(data) == 0 {
+		return defaultDisputeWindow
+	}
+	var window uint64
+	pp.readBinary(data, &window)
+	return window
+}
+
+func (pp *PaychanPlugin) GetChannel(store types.KVStore, id uint64) *Channel {
+	data := store.Get(pp.ChannelKey(id))
+	if len(data) == 0 {
+		return nil
+	}
+	var ch *Channel
+	pp.readBinary(data, &ch)
+	return ch
+}
+
+func (pp *PaychanPlugin) setChannel(store types.KVStore, ch *Channel) {
+	store.Set(pp.ChannelKey(ch.ID), wire.BinaryBytes(ch))
+}
+
+func (pp *PaychanPlugin) SetOption(store types.KVStore, key string, value string) (log string) {
+	switch key {
+	case "dispute_window":
+		window, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || window == 0 {
+			return "Invalid dispute window " + value
+		}
+		store.Set(pp.disputeWindowKey(), wire.BinaryBytes(window))
+		return "Success"
+	}
+	return "Unrecognized option key " + key
+}
+
+func (pp *PaychanPlugin) CheckTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	_, res = pp.validateTx(store, ctx, txBytes)
+	return res
+}
+
+func (pp *PaychanPlugin) RunTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	tx, res := pp.validateTx(store, ctx, txBytes)
+	if res.IsErr() {
+		return res
+	}
+
+	switch tx := tx.(type) {
+	case *OpenTx:
+		ch := &Channel{
+			ID:           pp.nextID(store),
+			Sender:       ctx.Caller,
+			SenderPubKey: sm.GetAccount(store, ctx.Caller).PubKey,
+			Recipient:    tx.Recipient,
+			Coins:        ctx.Coins,
+		}
+		pp.setChannel(store, ch)
+		// The call coins fund the channel until it settles.
+		return tmsp.NewResultOK(wire.BinaryBytes(ch.ID), "")
+	case *CloseTx:
+		ch := pp.GetChannel(store, tx.ChannelID)
+		if tx.Payment != nil {
+			ch.Paid = tx.Payment.Amount
+		}
+		if !ch.IsClosing() {
+			ch.SettleHeight = pp.height + pp.GetDisputeWindow(store)
+			pp.addSettlement(store, ch.SettleHeight, ch.ID)
+		}
+		pp.setChannel(store, ch)
+	}
+
+	// Closing pays nothing in; its call coins go back.
+	pp.pay(store, ctx.Caller, ctx.Coins)
+	return tmsp.OK
+}
+
+// Query is the binary encoded ID of a channel.
+func (pp *PaychanPlugin) Query(store types.KVStore, query []byte) (res tmsp.Result) {
+	var id uint64
+	err := wire.ReadBinaryBytes(query, &id)
+	if err != nil {
+		return tmsp.ErrEncodingError.AppendLog("Error decoding query: " + err.Error())
+	}
+	ch := pp.GetChannel(store, id)
+	if ch == nil {
+		return tmsp.ErrUnknownRequest.AppendLog(Fmt("Channel %v not found", id))
+	}
+	return tmsp.NewResultOK(wire.BinaryBytes(ch), "")
+}
+
+func (pp *PaychanPlugin) InitChain(store types.KVStore, vals []*tmsp.Validator) {
+}
+
+func (pp *PaychanPlugin) BeginBlock(store types.KVStore, height uint64) {
+	pp.height = height
+}
+
+// Settles every channel whose dispute window ends at height.
+func (pp *PaychanPlugin) EndBlock(store types.KVStore, height uint64) []*tmsp.Validator {
+	ids := pp.getSettlements(store, height)
+	if len(ids) == 0 {
+		return nil
+	}
+	for _, id := range ids {
+		ch := pp.GetChannel(store, id)
+		pp.pay(store, ch.Recipient, ch.Paid)
+		pp.pay(store, ch.Sender, ch.Coins.Minus(ch.Paid))
+		ch.Settled = true
+		pp.setChannel(store, ch)
+	}
+	store.Set(pp.settleKey(height), nil)
+	return nil
+}
+
+//----------------------------------------
+
+// Decodes the tx and checks that the caller is a party to the channel.
+// Only the recipient may close with a payment, which must carry the
+// sender's signature.
+func (pp *PaychanPlugin) validateTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (Tx, tmsp.Result) {
+	var tx Tx
+	err := wire.ReadBinaryBytes(txBytes, &tx)
+	if err != nil {
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Error decoding tx: " + err.Error())
+	}
+
+	switch tx := tx.(type) {
+	case *OpenTx:
+		if len(tx.Recipient) != 20 {
+			return nil, tmsp.ErrBaseInvalidOutput.AppendLog("Invalid recipient address length")
+		}
+		if !ctx.Coins.IsPositive() {
+			return nil, tmsp.ErrBaseInsufficientFunds.AppendLog("Channel must lock some coins")
+		}
+	case *CloseTx:
+		ch := pp.GetChannel(store, tx.ChannelID)
+		if ch == nil {
+			return nil, tmsp.ErrUnknownRequest.AppendLog(Fmt("Channel %v not found", tx.ChannelID))
+		}
+		if ch.Settled {
+			return nil, tmsp.ErrUnknownRequest.AppendLog(Fmt("Channel %v is already settled", tx.ChannelID))
+		}
+		switch {
+		case bytes.Equal(ctx.Caller, ch.Recipient):
+			if res := pp.validatePayment(store, ch, tx.Payment); res.IsErr() {
+				return nil, res
+			}
+		case bytes.Equal(ctx.Caller, ch.Sender):
+			if tx.Payment != nil {
+				return nil, tmsp.ErrUnauthorized.AppendLog("Only the recipient may submit a payment")
+			}
+			if ch.IsClosing() {
+				return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Channel %v is already closing", tx.ChannelID))
+			}
+		default:
+			return nil, tmsp.ErrUnauthorized.AppendLog("Only the sender or recipient may close a channel")
+		}
+	default:
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Unknown tx type")
+	}
+	return tx, tmsp.OK
+}
+
+// A payment must be signed by the sender and pay more than any before it.
+func (pp *PaychanPlugin) validatePayment(store types.KVStore, ch *Channel, payment *Payment) tmsp.Result {
+	if payment == nil {
+		return tmsp.ErrEncodingError.AppendLog("Recipient must submit a payment")
+	}
+	if payment.ChannelID != ch.ID {
+		return tmsp.ErrEncodingError.AppendLog(Fmt("Payment is for channel %v", payment.ChannelID))
+	}
+	if !payment.Amount.IsValid() || !ch.Coins.IsGTE(payment.Amount) {
+		return tmsp.ErrBaseInsufficientFunds.AppendLog(
+			Fmt("Channel holds %v, cannot pay %v", ch.Coins, payment.Amount))
+	}
+	if payment.Amount.IsEqual(ch.Paid) || !payment.Amount.IsGTE(ch.Paid) {
+		return tmsp.ErrUnauthorized.AppendLog(
+			Fmt("Payment of %v does not exceed %v", payment.Amount, ch.Paid))
+	}
+	if !ch.SenderPubKey.VerifyBytes(payment.SignBytes(sm.GetChainID(store)), payment.Signature) {
+		return tmsp.ErrBaseInvalidSignature.AppendLog("Payment is not signed by the sender")
+	}
+	return tmsp.OK
+}
+
+func (pp *PaychanPlugin) pay(store types.KVStore, addr []byte, coins types.Coins) {
+	if !sm.TransferCoins(store, types.PluginAddress(pp.name), addr, coins) {
+		PanicSanity(Fmt("Plugin account should hold %v", coins))
+	}
+}
+
+func (pp *PaychanPlugin) nextID(store types.KVStore) uint64 {
+	var id uint64
+	if data := store.Get(pp.counterKey()); len(data) != 0 {
+		pp.readBinary(data, &id)
+	}
+	id += 1
+	store.Set(pp.counterKey(), wire.BinaryBytes(id))
+	return id
+}
+
+func (pp *PaychanPlugin) getSettlements(store types.KVStore, height uint64) (ids []uint64) {
+	data := store.Get(pp.settleKey(height))
+	if len(data) == 0 {
+		return nil
+	}
+	pp.readBinary(data, &ids)
+	return ids
+}
+
+func (pp *PaychanPlugin) addSettlement(store types.KVStore, height uint64, id uint64) {
+	ids := append(pp.getSettlements(store, height), id)
+	store.Set(pp.settleKey(height), wire.BinaryBytes(ids))
+}
+
+func (pp *PaychanPlugin) readBinary(data []byte, ptr interface{}) {
+	err := wire.ReadBinaryBytes(data, ptr)
+	if err != nil {
+		panic(Fmt("Error reading %v state %X error: %v", pp.name, data, err.Error()))
+	}
+}
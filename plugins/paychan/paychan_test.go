@@ -0,0 +1,73 @@
+package paychan
+
+import (
+	"testing"
+
+	"github.com/tendermint/basecoin/plugins/plugintest"
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	"github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+)
+
+const chainID = "test_chain_id"
+
+func TestPaychanCloseAndSettle(t *testing.T) {
+	store := types.NewMemKVStore()
+	store.Set(sm.ChainIDKey(), []byte(chainID))
+	pp := New("paychan")
+	pp.SetOption(store, "dispute_window", "10")
+
+	senderKey := crypto.GenPrivKeyEd25519()
+	sender := senderKey.PubKey().Address()
+	sm.SetAccount(store, sender, &types.Account{PubKey: senderKey.PubKey()})
+	recipient := []byte("recipient_address_00")
+
+	pp.BeginBlock(store, 1)
+	res := plugintest.RunTx(pp, store, sender, types.Coins{{"", 100}}, TxBytes(&OpenTx{Recipient: recipient}))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error opening channel: %v", res)
+	}
+	var id uint64
+	if err := wire.ReadBinaryBytes(res.Data, &id); err != nil {
+		t.Fatalf("Unexpected result data %X: %v", res.Data, err)
+	}
+
+	signedFor := func(chainID string, amount int64) *Payment {
+		p := &Payment{ChannelID: id, Amount: types.Coins{{"", amount}}}
+		p.Signature = senderKey.Sign(p.SignBytes(chainID))
+		return p
+	}
+	payment := func(amount int64) *Payment {
+		return signedFor(chainID, amount)
+	}
+
+	// The sender starts closing, the recipient disputes with the latest payment.
+	if res := plugintest.RunTx(pp, store, sender, types.Coins{{"", 1}}, TxBytes(&CloseTx{ChannelID: id})); res.IsErr() {
+		t.Fatalf("Unexpected error closing as sender: %v", res)
+	}
+	pp.BeginBlock(store, 5)
+	forged := payment(50)
+	forged.Amount = types.Coins{{"", 90}}
+	if res := plugintest.RunTx(pp, store, recipient, types.Coins{{"", 1}}, TxBytes(&CloseTx{ChannelID: id, Payment: forged})); res.IsOK() {
+		t.Fatal("Expected a forged payment to be rejected")
+	}
+	otherChain := signedFor("other_chain_id", 90)
+	if res := plugintest.RunTx(pp, store, recipient, types.Coins{{"", 1}}, TxBytes(&CloseTx{ChannelID: id, Payment: otherChain})); res.IsOK() {
+		t.Fatal("Expected a payment signed for another chain to be rejected")
+	}
+	if res := plugintest.RunTx(pp, store, recipient, types.Coins{{"", 1}}, TxBytes(&CloseTx{ChannelID: id, Payment: payment(30)})); res.IsErr() {
+		t.Fatalf("Unexpected error closing as recipient: %v", res)
+	}
+
+	pp.EndBlock(store, 11)
+	if acc := sm.GetAccount(store, sender); !acc.Balance.IsEqual(types.Coins{{"", 71}}) {
+		t.Errorf("Expected sender to get 70 back plus a refund, got %v", acc.Balance)
+	}
+	if acc := sm.GetAccount(store, recipient); !acc.Balance.IsEqual(types.Coins{{"", 31}}) {
+		t.Errorf("Expected recipient to be paid 30 plus a refund, got %v", acc.Balance)
+	}
+	if ch := pp.GetChannel(store, id); !ch.Settled {
+		t.Error("Expected channel to be settled")
+	}
+}
@@ -0,0 +1,22 @@
+// Package plugintest has helpers for testing plugins without going
+// through ExecTx.
+package plugintest
+
+import (
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+// A plugin that knows the name it's registered under.
+type NamedPlugin interface {
+	types.Plugin
+	Name() string
+}
+
+// Runs txBytes as if ExecTx had moved coins from caller into the plugin account.
+func RunTx(plugin NamedPlugin, store types.KVStore, caller []byte, coins types.Coins, txBytes []byte) tmsp.Result {
+	sm.AddCoins(store, types.PluginAddress(plugin.Name()), coins)
+	ctx := types.NewCallContext(caller, coins)
+	return plugin.RunTx(store, ctx, txBytes)
+}
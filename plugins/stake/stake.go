@@ -0,0 +1,483 @@
+package stake
+
+import (
+	"strconv"
+
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+const (
+	defaultUnbondingPeriod = 100
+	defaultMinBond         = 1
+	defaultMaxValidators   = 100
+)
+
+/*
+Tx is the data of an AppTx sent to the stake plugin.
+
+  - BondTx        Bond the call coins to a validator, with its consent
+  - UnbondTx      Start unbonding coins from a validator
+*/
+type Tx interface {
+	AssertIsTx()
+}
+
+const (
+	TxTypeBond   = byte(0x01)
+	TxTypeUnbond = byte(0x02)
+)
+
+func (_ *BondTx) AssertIsTx()   {}
+func (_ *UnbondTx) AssertIsTx() {}
+
+var _ = wire.RegisterInterface(
+	struct{ Tx }{},
+	wire.ConcreteType{&BondTx{}, TxTypeBond},
+	wire.ConcreteType{&UnbondTx{}, TxTypeUnbond},
+)
+
+// Encodes tx as the data of an AppTx.
+func TxBytes(tx Tx) []byte {
+	return wire.BinaryBytes(struct{ Tx }{tx})
+}
+
+type BondTx struct {
+	PubKey    crypto.PubKey    `json:"pub_key"`   // Of the validator
+	Signature crypto.Signature `json:"signature"` // Of BondSignBytes(), by the validator
+}
+
+type UnbondTx struct {
+	PubKey crypto.PubKey `json:"pub_key"` // Of the validator
+	Amount int64         `json:"amount"`
+}
+
+//----------------------------------------
+
+// Returns the bytes a validator signs to let bonder bond coins to it,
+// which proves the bonder didn't pick a key it doesn't control.
+func BondSignBytes(chainID string, bonder []byte) []byte {
+	signBytes := wire.BinaryBytes(chainID)
+	signBytes = append(signBytes, []byte("stake/bond/")...)
+	return append(signBytes, bonder...)
+}
+
+// A validator's voting power is its power at genesis plus the total
+// amount bonded to it.
+type Validator struct {
+	PubKey       crypto.PubKey `json:"pub_key"`
+	GenesisPower uint64        `json:"genesis_power"`
+	Bonded       int64         `json:"bonded"`
+}
+
+func (val *Validator) Power() uint64 {
+	return val.GenesisPower + uint64(val.Bonded)
+}
+
+// Coins an address has bonded to a validator. The denom is kept with
+// the bond, so the bond denom option can change without stranding them.
+type Bond struct {
+	Denom  string `json:"denom"`
+	Amount int64  `json:"amount"`
+}
+
+// Coins that get paid back to Address at the end of block Height.
+type Unbonding struct {
+	Address []byte `json:"address"`
+	Denom   string `json:"denom"`
+	Amount  int64  `json:"amount"`
+}
+
+//----------------------------------------
+
+type StakePlugin struct {
+	name   string
+	height uint64
+}
+
+func New(name string) *StakePlugin {
+	return &StakePlugin{
+		name: name,
+	}
+}
+
+func (sp *StakePlugin) Name() string {
+	return sp.name
+}
+
+func (sp *StakePlugin) ValidatorKey(pubKey crypto.PubKey) []byte {
+	return append([]byte(sp.name+"/v/"), pubKey.Bytes()...)
+}
+
+func (sp *StakePlugin) BondKey(addr []byte, pubKey crypto.PubKey) []byte {
+	key := append([]byte(sp.name+"/b/"), addr...)
+	return append(key, pubKey.Bytes()...)
+}
+
+func (sp *StakePlugin) unbondingKey(height uint64) []byte {
+	return []byte(Fmt("%v/u/%v", sp.name, height))
+}
+
+// Validators whose power changed during the current block.
+func (sp *StakePlugin) changedKey() []byte {
+	return []byte(sp.name + "/changed")
+}
+
+func (sp *StakePlugin) denomKey() []byte {
+	return []byte(sp.name + "/denom")
+}
+
+func (sp *StakePlugin) unbondingPeriodKey() []byte {
+	return []byte(sp.name + "/unbonding_period")
+}
+
+func (sp *StakePlugin) minBondKey() []byte {
+	return []byte(sp.name + "/min_bond")
+}
+
+func (sp *StakePlugin) maxValidatorsKey() []byte {
+	return []byte(sp.name + "/max_validators")
+}
+
+// The number of validators with power.
+func (sp *StakePlugin) validatorCountKey() []byte {
+	return []byte(sp.name + "/count")
+}
+
+// Returns the denom of the coins that can be bonded.
+func (sp *StakePlugin) GetDenom(store types.KVStore) string {
+	return string(store.Get(sp.denomKey()))
+}
+
+// Returns the number of blocks that unbonding coins stay locked.
+func (sp *StakePlugin) GetUnbondingPeriod(store types.KVStore) uint64 {
+	data := store.Get(sp.unbondingPeriodKey())
+	if len(data) == 0 {
+		return defaultUnbondingPeriod
+	}
+	var period uint64
+	sp.readBinary(data, &period)
+	return period
+}
+
+// Returns the smallest amount a BondTx may bond.
+func (sp *StakePlugin) GetMinBond(store types.KVStore) int64 {
+	data := store.Get(sp.minBondKey())
+	if len(data) == 0 {
+		return defaultMinBond
+	}
+	var minBond int64
+	sp.readBinary(data, &minBond)
+	return minBond
+}
+
+// Returns how many validators may have power at once.
+func (sp *StakePlugin) GetMaxValidators(store types.KVStore) int {
+	data := store.Get(sp.maxValidatorsKey())
+	if len(data) == 0 {
+		return defaultMaxValidators
+	}
+	var max int
+	sp.readBinary(data, &max)
+	return max
+}
+
+func (sp *StakePlugin) getValidatorCount(store types.KVStore) int {
+	data := store.Get(sp.validatorCountKey())
+	if len(data) == 0 {
+		return 0
+	}
+	var count int
+	sp.readBinary(data, &count)
+	return count
+}
+
+func (sp *StakePlugin) GetValidator(store types.KVStore, pubKey crypto.PubKey) *Validator {
+	data := store.Get(sp.ValidatorKey(pubKey))
+	if len(data) == 0 {
+		return nil
+	}
+	var val *Validator
+	sp.readBinary(data, &val)
+	return val
+}
+
+// Returns what addr has bonded to the validator, or nil if nothing.
+func (sp *StakePlugin) GetBond(store types.KVStore, addr []byte, pubKey crypto.PubKey) *Bond {
+	data := store.Get(sp.BondKey(addr, pubKey))
+	if len(data) == 0 {
+		return nil
+	}
+	var bond *Bond
+	sp.readBinary(data, &bond)
+	return bond
+}
+
+func (sp *StakePlugin) SetOption(store types.KVStore, key string, value string) (log string) {
+	switch key {
+	case "denom":
+		store.Set(sp.denomKey(), []byte(value))
+		return "Success"
+	case "unbonding_period":
+		period, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return "Invalid unbonding period " + value
+		}
+		store.Set(sp.unbondingPeriodKey(), wire.BinaryBytes(period))
+		return "Success"
+	case "min_bond":
+		minBond, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || minBond <= 0 {
+			return "Invalid min bond " + value
+		}
+		store.Set(sp.minBondKey(), wire.BinaryBytes(minBond))
+		return "Success"
+	case "max_validators":
+		max, err := strconv.Atoi(value)
+		if err != nil || max <= 0 {
+			return "Invalid max validators " + value
+		}
+		store.Set(sp.maxValidatorsKey(), wire.BinaryBytes(max))
+		return "Success"
+	}
+	return "Unrecognized option key " + key
+}
+
+func (sp *StakePlugin) CheckTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	_, res = sp.validateTx(store, ctx, txBytes)
+	return res
+}
+
+func (sp *StakePlugin) RunTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (res tmsp.Result) {
+	tx, res := sp.validateTx(store, ctx, txBytes)
+	if res.IsErr() {
+		return res
+	}
+
+	var bonded types.Coins
+	switch tx := tx.(type) {
+	case *BondTx:
+		denom := sp.GetDenom(store)
+		amount := sp.bondAmount(store, ctx.Coins)
+		bonded = types.Coins{{denom, amount}}
+		sp.adjustBond(store, ctx.Caller, tx.PubKey, denom, amount)
+	case *UnbondTx:
+		denom := sp.GetBond(store, ctx.Caller, tx.PubKey).Denom
+		sp.adjustBond(store, ctx.Caller, tx.PubKey, denom, -tx.Amount)
+		sp.addUnbonding(store, sp.height+sp.GetUnbondingPeriod(store), Unbonding{
+			Address: ctx.Caller,
+			Denom:   denom,
+			Amount:  tx.Amount,
+		})
+	}
+
+	// Bonded coins stay in the plugin account, the rest goes back.
+	sp.pay(store, ctx.Caller, ctx.Coins.Minus(bonded))
+	return tmsp.OK
+}
+
+// Query is the binary encoded PubKey of a validator.
+func (sp *StakePlugin) Query(store types.KVStore, query []byte) (res tmsp.Result) {
+	var pubKey crypto.PubKey
+	err := wire.ReadBinaryBytes(query, &pubKey)
+	if err != nil {
+		return tmsp.ErrEncodingError.AppendLog("Error decoding query: " + err.Error())
+	}
+	val := sp.GetValidator(store, pubKey)
+	if val == nil {
+		return tmsp.ErrUnknownRequest.AppendLog(Fmt("Validator %v not found", pubKey))
+	}
+	return tmsp.NewResultOK(wire.BinaryBytes(val), "")
+}
+
+// Records the genesis validators, so bonds add to their power
+// rather than replace it.
+func (sp *StakePlugin) InitChain(store types.KVStore, vals []*tmsp.Validator) {
+	count := 0
+	for _, genesisVal := range vals {
+		var pubKey crypto.PubKey
+		err := wire.ReadBinaryBytes(genesisVal.PubKey, &pubKey)
+		if err != nil || genesisVal.Power == 0 {
+			continue
+		}
+		val := &Validator{
+			PubKey:       pubKey,
+			GenesisPower: genesisVal.Power,
+		}
+		store.Set(sp.ValidatorKey(pubKey), wire.BinaryBytes(val))
+		count += 1
+	}
+	store.Set(sp.validatorCountKey(), wire.BinaryBytes(count))
+}
+
+func (sp *StakePlugin) BeginBlock(store types.KVStore, height uint64) {
+	sp.height = height
+}
+
+// Pays out matured unbondings and reports the power of every validator
+// whose bond changed during the block.
+func (sp *StakePlugin) EndBlock(store types.KVStore, height uint64) (diffs []*tmsp.Validator) {
+	if unbondings := sp.getUnbondings(store, height); len(unbondings) > 0 {
+		for _, unbonding := range unbondings {
+			sp.pay(store, unbonding.Address, types.Coins{{unbonding.Denom, unbonding.Amount}})
+		}
+		store.Set(sp.unbondingKey(height), nil)
+	}
+
+	changed := sp.getChanged(store)
+	if len(changed) == 0 {
+		return nil
+	}
+	for _, pubKey := range changed {
+		val := sp.GetValidator(store, pubKey)
+		diffs = append(diffs, &tmsp.Validator{
+			PubKey: pubKey.Bytes(),
+			Power:  val.Power(),
+		})
+	}
+	store.Set(sp.changedKey(), nil)
+	return diffs
+}
+
+//----------------------------------------
+
+// Decodes the tx and checks that a bond is signed by the validator
+// and meets the minimum, and that an unbond doesn't exceed the bond.
+func (sp *StakePlugin) validateTx(store types.KVStore, ctx types.CallContext, txBytes []byte) (Tx, tmsp.Result) {
+	var tx Tx
+	err := wire.ReadBinaryBytes(txBytes, &tx)
+	if err != nil {
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Error decoding tx: " + err.Error())
+	}
+
+	switch tx := tx.(type) {
+	case *BondTx:
+		if tx.PubKey == nil {
+			return nil, tmsp.ErrEncodingError.AppendLog("Validator PubKey cannot be nil")
+		}
+		signBytes := BondSignBytes(sm.GetChainID(store), ctx.Caller)
+		if tx.Signature == nil || !tx.PubKey.VerifyBytes(signBytes, tx.Signature) {
+			return nil, tmsp.ErrUnauthorized.AppendLog("Bond must be signed by the validator")
+		}
+		denom := sp.GetDenom(store)
+		if minBond := sp.GetMinBond(store); sp.bondAmount(store, ctx.Coins) < minBond {
+			return nil, tmsp.ErrBaseInsufficientFunds.AppendLog(
+				Fmt("Must send at least %v %v coins along to bond them", minBond, denom))
+		}
+		if bond := sp.GetBond(store, ctx.Caller, tx.PubKey); bond != nil && bond.Denom != denom {
+			return nil, tmsp.ErrUnauthorized.AppendLog(
+				Fmt("Must unbond %v %v before bonding %v", bond.Amount, bond.Denom, denom))
+		}
+		val := sp.GetValidator(store, tx.PubKey)
+		if val == nil || val.Power() == 0 {
+			if max := sp.GetMaxValidators(store); sp.getValidatorCount(store) >= max {
+				return nil, tmsp.ErrUnauthorized.AppendLog(
+					Fmt("Already at the maximum of %v validators", max))
+			}
+		}
+	case *UnbondTx:
+		if tx.PubKey == nil {
+			return nil, tmsp.ErrEncodingError.AppendLog("Validator PubKey cannot be nil")
+		}
+		if tx.Amount <= 0 {
+			return nil, tmsp.ErrEncodingError.AppendLog("Amount must be positive")
+		}
+		if bond := sp.GetBond(store, ctx.Caller, tx.PubKey); bond == nil || bond.Amount < tx.Amount {
+			bonded := int64(0)
+			if bond != nil {
+				bonded = bond.Amount
+			}
+			return nil, tmsp.ErrBaseInsufficientFunds.AppendLog(
+				Fmt("Cannot unbond %v, only %v is bonded", tx.Amount, bonded))
+		}
+	default:
+		return nil, tmsp.ErrBaseEncodingError.AppendLog("Unknown tx type")
+	}
+	return tx, tmsp.OK
+}
+
+// Returns how much of coins is in the bond denom.
+func (sp *StakePlugin) bondAmount(store types.KVStore, coins types.Coins) int64 {
+	denom := sp.GetDenom(store)
+	for _, coin := range coins {
+		if coin.Denom == denom {
+			return coin.Amount
+		}
+	}
+	return 0
+}
+
+// Changes the bond of addr and the power of the validator by amount.
+func (sp *StakePlugin) adjustBond(store types.KVStore, addr []byte, pubKey crypto.PubKey, denom string, amount int64) {
+	bond := sp.GetBond(store, addr, pubKey)
+	if bond == nil {
+		bond = &Bond{Denom: denom}
+	}
+	bond.Amount += amount
+	if bond.Amount == 0 {
+		store.Set(sp.BondKey(addr, pubKey), nil)
+	} else {
+		store.Set(sp.BondKey(addr, pubKey), wire.BinaryBytes(bond))
+	}
+
+	val := sp.GetValidator(store, pubKey)
+	if val == nil {
+		val = &Validator{PubKey: pubKey}
+	}
+	before := val.Power()
+	val.Bonded += amount
+	store.Set(sp.ValidatorKey(pubKey), wire.BinaryBytes(val))
+	if count := sp.getValidatorCount(store); before == 0 && val.Power() != 0 {
+		store.Set(sp.validatorCountKey(), wire.BinaryBytes(count+1))
+	} else if before != 0 && val.Power() == 0 {
+		store.Set(sp.validatorCountKey(), wire.BinaryBytes(count-1))
+	}
+
+	changed := sp.getChanged(store)
+	for _, other := range changed {
+		if other.Equals(pubKey) {
+			return
+		}
+	}
+	store.Set(sp.changedKey(), wire.BinaryBytes(append(changed, pubKey)))
+}
+
+func (sp *StakePlugin) getChanged(store types.KVStore) (pubKeys []crypto.PubKey) {
+	data := store.Get(sp.changedKey())
+	if len(data) == 0 {
+		return nil
+	}
+	sp.readBinary(data, &pubKeys)
+	return pubKeys
+}
+
+func (sp *StakePlugin) getUnbondings(store types.KVStore, height uint64) (unbondings []Unbonding) {
+	data := store.Get(sp.unbondingKey(height))
+	if len(data) == 0 {
+		return nil
+	}
+	sp.readBinary(data, &unbondings)
+	return unbondings
+}
+
+func (sp *StakePlugin) addUnbonding(store types.KVStore, height uint64, unbonding Unbonding) {
+	unbondings := append(sp.getUnbondings(store, height), unbonding)
+	store.Set(sp.unbondingKey(height), wire.BinaryBytes(unbondings))
+}
+
+func (sp *StakePlugin) pay(store types.KVStore, addr []byte, coins types.Coins) {
+	if !sm.TransferCoins(store, types.PluginAddress(sp.name), addr, coins) {
+		PanicSanity(Fmt("Plugin account should hold %v", coins))
+	}
+}
+
+func (sp *StakePlugin) readBinary(data []byte, ptr interface{}) {
+	err := wire.ReadBinaryBytes(data, ptr)
+	if err != nil {
+		panic(Fmt("Error reading %v state %X error: %v", sp.name, data, err.Error()))
+	}
+}
@@ -0,0 +1,143 @@
+package stake
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tendermint/basecoin/plugins/plugintest"
+	sm "github.com/tendermint/basecoin/state"
+	"github.com/tendermint/basecoin/types"
+	"github.com/tendermint/go-crypto"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+const chainID = "test_chain_id"
+
+var delegator = []byte("delegator_address_00")
+
+func newStore() types.KVStore {
+	store := types.NewMemKVStore()
+	store.Set(sm.ChainIDKey(), []byte(chainID))
+	return store
+}
+
+// Returns a BondTx signed by the validator for the bonder.
+func signedBondTx(valPrivKey crypto.PrivKey, chainID string, bonder []byte) *BondTx {
+	return &BondTx{
+		PubKey:    valPrivKey.PubKey(),
+		Signature: valPrivKey.Sign(BondSignBytes(chainID, bonder)),
+	}
+}
+
+func TestStakeBondUnbond(t *testing.T) {
+	store := newStore()
+	sp := New("stake")
+	sp.SetOption(store, "unbonding_period", "10")
+	valPrivKey := crypto.GenPrivKeyEd25519()
+	valPubKey := valPrivKey.PubKey()
+
+	sp.BeginBlock(store, 1)
+	res := plugintest.RunTx(sp, store, delegator, types.Coins{{"", 100}}, TxBytes(signedBondTx(valPrivKey, chainID, delegator)))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error bonding: %v", res)
+	}
+	diffs := sp.EndBlock(store, 1)
+	if len(diffs) != 1 || !bytes.Equal(diffs[0].PubKey, valPubKey.Bytes()) || diffs[0].Power != 100 {
+		t.Fatalf("Expected validator with power 100, got %v", diffs)
+	}
+
+	sp.BeginBlock(store, 2)
+	if diffs := sp.EndBlock(store, 2); len(diffs) != 0 {
+		t.Fatalf("Expected no diffs without bond changes, got %v", diffs)
+	}
+
+	// Changing the bond denom doesn't change what the bond pays back.
+	sp.SetOption(store, "denom", "atom")
+	sp.BeginBlock(store, 3)
+	res = plugintest.RunTx(sp, store, delegator, types.Coins{{"", 1}}, TxBytes(&UnbondTx{PubKey: valPubKey, Amount: 101}))
+	if res.IsOK() {
+		t.Fatal("Expected unbonding more than was bonded to fail")
+	}
+	res = plugintest.RunTx(sp, store, delegator, types.Coins{{"", 1}}, TxBytes(&UnbondTx{PubKey: valPubKey, Amount: 100}))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error unbonding: %v", res)
+	}
+	diffs = sp.EndBlock(store, 3)
+	if len(diffs) != 1 || diffs[0].Power != 0 {
+		t.Fatalf("Expected validator to be removed, got %v", diffs)
+	}
+	if acc := sm.GetAccount(store, delegator); !acc.Balance.IsEqual(types.Coins{{"", 1}}) {
+		t.Fatalf("Expected unbonded coins to stay locked, got %v", acc.Balance)
+	}
+
+	sp.BeginBlock(store, 13)
+	sp.EndBlock(store, 13)
+	if acc := sm.GetAccount(store, delegator); !acc.Balance.IsEqual(types.Coins{{"", 101}}) {
+		t.Fatalf("Expected unbonded coins to be paid back, got %v", acc.Balance)
+	}
+}
+
+func TestStakeBondNeedsValidatorSignature(t *testing.T) {
+	store := newStore()
+	sp := New("stake")
+	valPrivKey := crypto.GenPrivKeyEd25519()
+	otherPrivKey := crypto.GenPrivKeyEd25519()
+	sp.BeginBlock(store, 1)
+
+	unsigned := &BondTx{PubKey: valPrivKey.PubKey()}
+	if res := plugintest.RunTx(sp, store, delegator, types.Coins{{"", 100}}, TxBytes(unsigned)); res.IsOK() {
+		t.Fatal("Expected an unsigned bond to fail")
+	}
+	wrongKey := &BondTx{
+		PubKey:    valPrivKey.PubKey(),
+		Signature: otherPrivKey.Sign(BondSignBytes(chainID, delegator)),
+	}
+	if res := plugintest.RunTx(sp, store, delegator, types.Coins{{"", 100}}, TxBytes(wrongKey)); res.IsOK() {
+		t.Fatal("Expected a bond signed by another key to fail")
+	}
+	otherChain := signedBondTx(valPrivKey, "other_chain_id", delegator)
+	if res := plugintest.RunTx(sp, store, delegator, types.Coins{{"", 100}}, TxBytes(otherChain)); res.IsOK() {
+		t.Fatal("Expected a bond signed for another chain to fail")
+	}
+	otherBonder := signedBondTx(valPrivKey, chainID, []byte("someone_else_0000000"))
+	if res := plugintest.RunTx(sp, store, delegator, types.Coins{{"", 100}}, TxBytes(otherBonder)); res.IsOK() {
+		t.Fatal("Expected a bond signed for another bonder to fail")
+	}
+}
+
+func TestStakeLimits(t *testing.T) {
+	store := newStore()
+	sp := New("stake")
+	sp.SetOption(store, "min_bond", "10")
+	sp.SetOption(store, "max_validators", "2")
+	genesisPrivKey := crypto.GenPrivKeyEd25519()
+	sp.InitChain(store, []*tmsp.Validator{{PubKey: genesisPrivKey.PubKey().Bytes(), Power: 50}})
+	sp.BeginBlock(store, 1)
+
+	res := plugintest.RunTx(sp, store, delegator, types.Coins{{"", 5}}, TxBytes(signedBondTx(genesisPrivKey, chainID, delegator)))
+	if res.IsOK() {
+		t.Fatal("Expected a bond below the minimum to fail")
+	}
+
+	// Bonding adds to the genesis power.
+	res = plugintest.RunTx(sp, store, delegator, types.Coins{{"", 10}}, TxBytes(signedBondTx(genesisPrivKey, chainID, delegator)))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error bonding: %v", res)
+	}
+	diffs := sp.EndBlock(store, 1)
+	if len(diffs) != 1 || diffs[0].Power != 60 {
+		t.Fatalf("Expected genesis validator with power 60, got %v", diffs)
+	}
+
+	sp.BeginBlock(store, 2)
+	secondPrivKey := crypto.GenPrivKeyEd25519()
+	res = plugintest.RunTx(sp, store, delegator, types.Coins{{"", 10}}, TxBytes(signedBondTx(secondPrivKey, chainID, delegator)))
+	if res.IsErr() {
+		t.Fatalf("Unexpected error bonding: %v", res)
+	}
+	thirdPrivKey := crypto.GenPrivKeyEd25519()
+	res = plugintest.RunTx(sp, store, delegator, types.Coins{{"", 10}}, TxBytes(signedBondTx(thirdPrivKey, chainID, delegator)))
+	if res.IsOK() {
+		t.Fatal("Expected a third validator to be over the maximum")
+	}
+}
@@ -1,17 +1,18 @@
 package state
 
 import (
+	"time"
+
 	"github.com/tendermint/basecoin/types"
 	. "github.com/tendermint/go-common"
 	"github.com/tendermint/go-events"
+	"github.com/tendermint/go-wire"
 	tmsp "github.com/tendermint/tmsp/types"
 )
 
 // If the tx is invalid, a TMSP error will be returned.
 func ExecTx(state *State, pgz *types.Plugins, tx types.Tx, isCheckTx bool, evc events.Fireable) tmsp.Result {
 
-	// TODO: do something with fees
-	fees := types.Coins{}
 	chainID := state.GetChainID()
 
 	// Exec tx
@@ -33,15 +34,21 @@ func ExecTx(state *State, pgz *types.Plugins, tx types.Tx, isCheckTx bool, evc e
 			return res.PrependLog("in getInputs()")
 		}
 
+		// Resolve names in outputs to addresses.
+		outs, res := resolveOutputs(state, pgz, tx.Outputs)
+		if res.IsErr() {
+			return res.PrependLog("in resolveOutputs()")
+		}
+
 		// Get or make outputs.
-		accounts, res = getOrMakeOutputs(state, accounts, tx.Outputs)
+		accounts, res = getOrMakeOutputs(state, accounts, outs)
 		if res.IsErr() {
 			return res.PrependLog("in getOrMakeOutputs()")
 		}
 
 		// Validate inputs and outputs, advanced
 		signBytes := tx.SignBytes(chainID)
-		inTotal, res := validateInputsAdvanced(accounts, signBytes, tx.Inputs)
+		inTotal, res := validateInputsAdvanced(state, accounts, signBytes, tx.Inputs)
 		if res.IsErr() {
 			return res.PrependLog("in validateInputsAdvanced()")
 		}
@@ -49,13 +56,15 @@ func ExecTx(state *State, pgz *types.Plugins, tx types.Tx, isCheckTx bool, evc e
 		if !inTotal.IsEqual(outTotal.Plus(types.Coins{{"", tx.Fee}})) {
 			return tmsp.ErrBaseInvalidOutput.AppendLog("Input total != output total + fees")
 		}
-		fees = fees.Plus(types.Coins{{"", tx.Fee}})
 
 		// TODO: Fee validation for SendTx
 
 		// Good! Adjust accounts
 		adjustByInputs(state, accounts, tx.Inputs)
-		adjustByOutputs(state, accounts, tx.Outputs, isCheckTx)
+		adjustByOutputs(state, accounts, outs, isCheckTx)
+		if !isCheckTx {
+			payFee(state, tx.Fee)
+		}
 
 		/*
 			// Fire events
@@ -71,6 +80,14 @@ func ExecTx(state *State, pgz *types.Plugins, tx types.Tx, isCheckTx bool, evc e
 			}
 		*/
 
+		// Report what the names resolved to, in output order.
+		if hasNamedOutputs(tx.Outputs) {
+			addrs := make([][]byte, len(outs))
+			for i, out := range outs {
+				addrs[i] = out.Address
+			}
+			return tmsp.NewResultOK(wire.BinaryBytes(addrs), "")
+		}
 		return tmsp.OK
 
 	case *types.AppTx:
@@ -91,7 +108,7 @@ func ExecTx(state *State, pgz *types.Plugins, tx types.Tx, isCheckTx bool, evc e
 
 		// Validate input, advanced
 		signBytes := tx.SignBytes(chainID)
-		res = validateInputAdvanced(inAcc, signBytes, tx.Input)
+		res = validateInputAdvanced(inAcc, state.GetLockedCoins(tx.Input.Address), signBytes, tx.Input)
 		if res.IsErr() {
 			log.Info(Fmt("validateInputAdvanced failed on %X: %v", tx.Input.Address, res))
 			return res.PrependLog("in validateInputAdvanced()")
@@ -107,14 +124,28 @@ func ExecTx(state *State, pgz *types.Plugins, tx types.Tx, isCheckTx bool, evc e
 			return tmsp.ErrBaseUnknownAddress.AppendLog(
 				Fmt("Unrecognized type byte %v", tx.Type))
 		}
+		if name := pgz.GetNameByByte(tx.Type); !IsPluginEnabled(state, name) {
+			return tmsp.ErrBaseUnknownAddress.AppendLog(
+				Fmt("Plugin %v is not enabled on this chain", name))
+		}
 
 		// Good!
 		coins := tx.Input.Coins.Minus(types.Coins{{"", tx.Fee}})
 		inAcc.Sequence += 1
 		inAcc.Balance = inAcc.Balance.Minus(tx.Input.Coins)
+		ctx := types.NewCallContext(tx.Input.Address, coins)
 
-		// If this is a CheckTx, stop now.
+		// If this is a CheckTx, let the plugin validate the data and stop now.
 		if isCheckTx {
+			if checker, ok := plugin.(types.TxChecker); ok {
+				// The cache is never synced, so the plugin can't mutate state.
+				cache := state.CacheWrap()
+				cache.SetAccount(tx.Input.Address, inAcc)
+				res = checker.CheckTx(cache, ctx, tx.Data)
+				if res.IsErr() {
+					return res.PrependLog("in plugin CheckTx()")
+				}
+			}
 			state.SetAccount(tx.Input.Address, inAcc)
 			return tmsp.OK
 		}
@@ -126,8 +157,17 @@ func ExecTx(state *State, pgz *types.Plugins, tx types.Tx, isCheckTx bool, evc e
 		// XXX cache := types.NewStateCache(state)
 		cache := state.CacheWrap()
 		cache.SetAccount(tx.Input.Address, inAcc)
-		ctx := types.NewCallContext(tx.Input.Address, coins)
+		AddCoins(cache, types.PluginAddress(pgz.GetNameByByte(tx.Type)), coins)
+		start := time.Now()
 		res = plugin.RunTx(cache, ctx, tx.Data)
+		if evc != nil {
+			pluginName := pgz.GetNameByByte(tx.Type)
+			evc.FireEvent(types.EventStringRunTx(pluginName), types.EventDataRunTx{
+				Plugin:   pluginName,
+				Duration: time.Since(start),
+				Code:     res.Code,
+			})
+		}
 		if res.IsOK() {
 			cache.CacheSync()
 			log.Info("Successful execution")
@@ -150,6 +190,7 @@ func ExecTx(state *State, pgz *types.Plugins, tx types.Tx, isCheckTx bool, evc e
 			// TODO
 			state.SetAccount(tx.Input.Address, inAccDeducted)
 		}
+		payFee(state, tx.Fee)
 		return res
 
 	default:
@@ -157,6 +198,13 @@ func ExecTx(state *State, pgz *types.Plugins, tx types.Tx, isCheckTx bool, evc e
 	}
 }
 
+// Fees go to the reward pool, so that they're paid out rather than destroyed.
+func payFee(store types.KVStore, fee int64) {
+	if fee > 0 {
+		AddCoins(store, RewardPoolAddress, types.Coins{{"", fee}})
+	}
+}
+
 //--------------------------------------------------------------------------------
 
 // The accounts from the TxInputs must either already have
@@ -181,6 +229,45 @@ func getInputs(state types.AccountGetter, ins []types.TxInput) (map[string]*type
 	return accounts, tmsp.OK
 }
 
+// Returns a copy of outs with names replaced by the addresses they resolve to.
+// The tx itself must not change, or its signatures would no longer match.
+func resolveOutputs(state *State, pgz *types.Plugins, outs []types.TxOutput) ([]types.TxOutput, tmsp.Result) {
+	if !hasNamedOutputs(outs) {
+		return outs, tmsp.OK
+	}
+	var resolver types.NameResolver
+	for _, plugin := range pgz.GetList() {
+		if r, ok := plugin.Plugin.(types.NameResolver); ok && IsPluginEnabled(state, plugin.Name) {
+			resolver = r
+			break
+		}
+	}
+	if resolver == nil {
+		return nil, tmsp.ErrBaseInvalidOutput.AppendLog("No plugin resolves names")
+	}
+	resolved := make([]types.TxOutput, len(outs))
+	for i, out := range outs {
+		if name, ok := out.Name(); ok {
+			addr := resolver.ResolveName(state, name)
+			if addr == nil {
+				return nil, tmsp.ErrBaseUnknownAddress.AppendLog(Fmt("Name %v is not registered", name))
+			}
+			out.Address = addr
+		}
+		resolved[i] = out
+	}
+	return resolved, tmsp.OK
+}
+
+func hasNamedOutputs(outs []types.TxOutput) bool {
+	for _, out := range outs {
+		if _, ok := out.Name(); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func getOrMakeOutputs(state types.AccountGetter, accounts map[string]*types.Account, outs []types.TxOutput) (map[string]*types.Account, tmsp.Result) {
 	if accounts == nil {
 		accounts = make(map[string]*types.Account)
@@ -216,13 +303,13 @@ func validateInputsBasic(ins []types.TxInput) (res tmsp.Result) {
 }
 
 // Validate inputs and compute total amount of coins
-func validateInputsAdvanced(accounts map[string]*types.Account, signBytes []byte, ins []types.TxInput) (total types.Coins, res tmsp.Result) {
+func validateInputsAdvanced(state *State, accounts map[string]*types.Account, signBytes []byte, ins []types.TxInput) (total types.Coins, res tmsp.Result) {
 	for _, in := range ins {
 		acc := accounts[string(in.Address)]
 		if acc == nil {
 			PanicSanity("validateInputsAdvanced() expects account in accounts")
 		}
-		res = validateInputAdvanced(acc, signBytes, in)
+		res = validateInputAdvanced(acc, state.GetLockedCoins(in.Address), signBytes, in)
 		if res.IsErr() {
 			return
 		}
@@ -232,14 +319,18 @@ func validateInputsAdvanced(accounts map[string]*types.Account, signBytes []byte
 	return total, tmsp.OK
 }
 
-func validateInputAdvanced(acc *types.Account, signBytes []byte, in types.TxInput) (res tmsp.Result) {
+// Locked coins must remain in the account after the input is taken out.
+func validateInputAdvanced(acc *types.Account, locked types.Coins, signBytes []byte, in types.TxInput) (res tmsp.Result) {
 	// Check sequence/coins
 	seq, balance := acc.Sequence, acc.Balance
 	if seq+1 != in.Sequence {
 		return tmsp.ErrBaseInvalidSequence.AppendLog(Fmt("Got %v, expected %v. (acc.seq=%v)", in.Sequence, seq+1, acc.Sequence))
 	}
 	// Check amount
-	if !balance.IsGTE(in.Coins) {
+	if !balance.IsGTE(in.Coins.Plus(locked)) {
+		if balance.IsGTE(in.Coins) {
+			return tmsp.ErrBaseInsufficientFunds.AppendLog(Fmt("%v of the balance is still vesting", locked))
+		}
 		return tmsp.ErrBaseInsufficientFunds
 	}
 	// Check signatures
@@ -0,0 +1,184 @@
+package state
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tendermint/basecoin/tests"
+	"github.com/tendermint/basecoin/types"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+// A plugin whose CheckTx writes to its store, then rejects the tx.
+type rejectingPlugin struct {
+	types.Plugin
+}
+
+func (rp rejectingPlugin) CheckTx(store types.KVStore, ctx types.CallContext, txBytes []byte) tmsp.Result {
+	store.Set([]byte("rejecting/checked"), []byte("yes"))
+	return tmsp.ErrBaseInvalidInput.AppendLog("rejected")
+}
+
+const testChainID = "test_chain_id"
+
+// Returns a state with one account holding 100 coins, and its key.
+func stateWithAccount(secret string) (*State, types.PrivAccount) {
+	state := NewState(types.NewMemKVStore())
+	state.SetChainID(testChainID)
+	privAcc := tests.PrivAccountFromSecret(secret)
+	acc := privAcc.Account
+	acc.Balance = types.Coins{{"", 100}}
+	state.SetAccount(acc.PubKey.Address(), &acc)
+	return state, privAcc
+}
+
+// Returns an AppTx from privAcc to the plugin with typeByte, paying it 10 coins.
+func signedAppTx(privAcc types.PrivAccount, typeByte byte) *types.AppTx {
+	tx := &types.AppTx{
+		Type: typeByte,
+		Input: types.TxInput{
+			Address:  privAcc.Account.PubKey.Address(),
+			Coins:    types.Coins{{"", 10}},
+			Sequence: 1,
+			PubKey:   privAcc.Account.PubKey,
+		},
+	}
+	tx.Input.Signature = privAcc.PrivKey.Sign(tx.SignBytes(testChainID))
+	return tx
+}
+
+func TestCheckTxRejectedByPlugin(t *testing.T) {
+	state, privAcc := stateWithAccount("checker")
+	pgz := types.NewPlugins()
+	pgz.RegisterPlugin(0x10, "rejecting", rejectingPlugin{})
+
+	checkState := state.CacheWrap()
+	res := ExecTx(checkState, pgz, signedAppTx(privAcc, 0x10), true, nil)
+	if res.Code != tmsp.CodeType_BaseInvalidInput {
+		t.Fatalf("Expected the plugin to reject the tx, got %v", res)
+	}
+	if len(checkState.Get([]byte("rejecting/checked"))) != 0 {
+		t.Error("Expected CheckTx writes to be thrown away")
+	}
+	got := checkState.GetAccount(privAcc.Account.PubKey.Address())
+	if got.Sequence != 0 || !got.Balance.IsEqual(types.Coins{{"", 100}}) {
+		t.Errorf("Expected the input account to be untouched, got %v", got)
+	}
+}
+
+func TestAppTxToDisabledPlugin(t *testing.T) {
+	state, privAcc := stateWithAccount("caller")
+	SetEnabledPlugins(state, []string{"other"})
+	pgz := types.NewPlugins()
+	pgz.RegisterPlugin(0x10, "rejecting", rejectingPlugin{})
+
+	res := ExecTx(state, pgz, signedAppTx(privAcc, 0x10), false, nil)
+	if res.Code != tmsp.CodeType_BaseUnknownAddress {
+		t.Fatalf("Expected a tx to a disabled plugin to be refused, got %v", res)
+	}
+	if len(state.Get([]byte("rejecting/checked"))) != 0 {
+		t.Error("Expected the disabled plugin not to run")
+	}
+}
+
+// A plugin that resolves "alice" to a fixed address.
+type namingPlugin struct {
+	types.Plugin
+}
+
+var aliceAddress = []byte("alice_address_000000")
+
+func (np namingPlugin) ResolveName(store types.KVStore, name string) []byte {
+	if name == "alice" {
+		return aliceAddress
+	}
+	return nil
+}
+
+func TestSendTxToName(t *testing.T) {
+	state, privAcc := stateWithAccount("sender")
+	acc := privAcc.Account
+	pgz := types.NewPlugins()
+	pgz.RegisterPlugin(0x10, "naming", namingPlugin{})
+
+	bob := []byte("bob_address_00000000")
+	newSendTx := func(name string) *types.SendTx {
+		tx := &types.SendTx{
+			Inputs: []types.TxInput{{
+				Address:  acc.PubKey.Address(),
+				Coins:    types.Coins{{"", 30}},
+				Sequence: 1,
+				PubKey:   acc.PubKey,
+			}},
+			Outputs: []types.TxOutput{
+				{Address: types.NameRef(name), Coins: types.Coins{{"", 10}}},
+				{Address: bob, Coins: types.Coins{{"", 20}}},
+			},
+		}
+		tx.Inputs[0].Signature = privAcc.PrivKey.Sign(tx.SignBytes(testChainID))
+		return tx
+	}
+
+	if res := ExecTx(state, pgz, newSendTx("bob"), false, nil); res.Code != tmsp.CodeType_BaseUnknownAddress {
+		t.Fatalf("Expected a send to an unregistered name to fail, got %v", res)
+	}
+	res := ExecTx(state, pgz, newSendTx("alice"), false, nil)
+	if res.IsErr() {
+		t.Fatalf("Unexpected error sending to a name: %v", res)
+	}
+	if expected := wire.BinaryBytes([][]byte{aliceAddress, bob}); !bytes.Equal(res.Data, expected) {
+		t.Errorf("Expected the result to report the resolved addresses %X, got %X", expected, res.Data)
+	}
+	if got := state.GetAccount(aliceAddress); got == nil || !got.Balance.IsEqual(types.Coins{{"", 10}}) {
+		t.Errorf("Expected alice's owner to be paid 10, got %v", got)
+	}
+	if got := state.GetAccount(bob); got == nil || !got.Balance.IsEqual(types.Coins{{"", 20}}) {
+		t.Errorf("Expected bob to be paid 20, got %v", got)
+	}
+}
+
+func TestSendTxFromVestingAccount(t *testing.T) {
+	state, privAcc := stateWithAccount("vester")
+	acc := privAcc.Account
+	SetVestingSchedule(state, acc.PubKey.Address(), &types.VestingSchedule{
+		StartHeight: 10,
+		EndHeight:   20,
+		Cliff:       true,
+		Coins:       types.Coins{{"", 80}},
+	})
+	pgz := types.NewPlugins()
+
+	bob := []byte("bob_address_00000000")
+	newSendTx := func(sequence int, amount int64) *types.SendTx {
+		tx := &types.SendTx{
+			Inputs: []types.TxInput{{
+				Address:  acc.PubKey.Address(),
+				Coins:    types.Coins{{"", amount}},
+				Sequence: sequence,
+			}},
+			Outputs: []types.TxOutput{{Address: bob, Coins: types.Coins{{"", amount}}}},
+		}
+		if sequence == 1 {
+			tx.Inputs[0].PubKey = acc.PubKey
+		}
+		tx.Inputs[0].Signature = privAcc.PrivKey.Sign(tx.SignBytes(testChainID))
+		return tx
+	}
+
+	state.SetBlockHeight(15)
+	if res := ExecTx(state, pgz, newSendTx(1, 30), false, nil); res.Code != tmsp.CodeType_BaseInsufficientFunds {
+		t.Fatalf("Expected spending locked coins to fail, got %v", res)
+	}
+	if res := ExecTx(state, pgz, newSendTx(1, 20), false, nil); res.IsErr() {
+		t.Fatalf("Unexpected error spending unlocked coins: %v", res)
+	}
+
+	state.SetBlockHeight(20)
+	if res := ExecTx(state, pgz, newSendTx(2, 80), false, nil); res.IsErr() {
+		t.Fatalf("Unexpected error spending coins after they unlocked: %v", res)
+	}
+	if got := state.GetAccount(bob); got == nil || !got.Balance.IsEqual(types.Coins{{"", 100}}) {
+		t.Errorf("Expected bob to be paid 100, got %v", got)
+	}
+}
@@ -0,0 +1,155 @@
+package state
+
+import (
+	"errors"
+
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+)
+
+/*
+IndexedKVStore records every key set through it in the order it was first
+set, so the state can be walked without support from the underlying store.
+The index lives in the same store:
+
+  - base/keys          The number of indexed keys
+  - base/keys/<n>      The nth key
+  - base/keys/start    The last committed height when the first key was indexed
+  - base/ki/<key>      The position of key in the index
+
+Setting an empty value removes the key, from the index and from the
+underlying store, which deletes it outright if it is a types.KVRemover.
+The last indexed key then takes the removed key's place in the index.
+Setting an empty value for a key that was never set writes nothing.
+*/
+type IndexedKVStore struct {
+	store types.KVStore
+}
+
+func NewIndexedKVStore(store types.KVStore) *IndexedKVStore {
+	return &IndexedKVStore{
+		store: store,
+	}
+}
+
+func (ikv *IndexedKVStore) Get(key []byte) (value []byte) {
+	return ikv.store.Get(key)
+}
+
+func (ikv *IndexedKVStore) Set(key []byte, value []byte) {
+	if len(value) == 0 {
+		ikv.remove(key)
+		return
+	}
+	if len(ikv.store.Get(indexPositionKey(key))) == 0 {
+		n := GetKeyCount(ikv.store)
+		if len(ikv.store.Get(indexStartKey())) == 0 {
+			ikv.store.Set(indexStartKey(), wire.BinaryBytes(GetLastHeight(ikv.store)))
+		}
+		ikv.store.Set(indexKey(n), key)
+		ikv.store.Set(indexPositionKey(key), wire.BinaryBytes(n))
+		ikv.store.Set(keyCountKey(), wire.BinaryBytes(n+1))
+	}
+	ikv.store.Set(key, value)
+}
+
+func (ikv *IndexedKVStore) remove(key []byte) {
+	data := ikv.store.Get(indexPositionKey(key))
+	if len(data) != 0 {
+		// Move the last key into the removed key's place.
+		var n uint64
+		err := wire.ReadBinaryBytes(data, &n)
+		if err != nil {
+			panic(Fmt("Error reading index position %X error: %v",
+				data, err.Error()))
+		}
+		last := GetKeyCount(ikv.store) - 1
+		if n != last {
+			lastKey := ikv.store.Get(indexKey(last))
+			ikv.store.Set(indexKey(n), lastKey)
+			ikv.store.Set(indexPositionKey(lastKey), wire.BinaryBytes(n))
+		}
+		removeKey(ikv.store, indexKey(last))
+		removeKey(ikv.store, indexPositionKey(key))
+		ikv.store.Set(keyCountKey(), wire.BinaryBytes(last))
+	}
+	if len(ikv.store.Get(key)) != 0 {
+		removeKey(ikv.store, key)
+	}
+}
+
+// Deletes key from store if it can, or else sets it empty.
+func removeKey(store types.KVStore, key []byte) {
+	if remover, ok := store.(types.KVRemover); ok {
+		remover.Remove(key)
+	} else {
+		store.Set(key, nil)
+	}
+}
+
+func keyCountKey() []byte {
+	return []byte("base/keys")
+}
+
+func indexKey(n uint64) []byte {
+	return []byte(Fmt("base/keys/%v", n))
+}
+
+func indexStartKey() []byte {
+	return []byte("base/keys/start")
+}
+
+func indexPositionKey(key []byte) []byte {
+	return append([]byte("base/ki/"), key...)
+}
+
+// Returns the number of indexed keys.
+func GetKeyCount(store types.KVStore) uint64 {
+	data := store.Get(keyCountKey())
+	if len(data) == 0 {
+		return 0
+	}
+	var count uint64
+	err := wire.ReadBinaryBytes(data, &count)
+	if err != nil {
+		panic(Fmt("Error reading key count %X error: %v",
+			data, err.Error()))
+	}
+	return count
+}
+
+// Returns an error unless the index was started before the first commit,
+// and so holds every key of the state.
+func CheckIndexComplete(store types.KVStore) error {
+	data := store.Get(indexStartKey())
+	if len(data) == 0 {
+		if GetKeyCount(store) != 0 || GetLastHeight(store) != 0 {
+			return errors.New("The state predates the key index")
+		}
+		return nil
+	}
+	var start uint64
+	err := wire.ReadBinaryBytes(data, &start)
+	if err != nil {
+		panic(Fmt("Error reading index start %X error: %v",
+			data, err.Error()))
+	}
+	if start != 0 {
+		return errors.New(Fmt("The key index started at height %v, keys set before then are missing", start))
+	}
+	return nil
+}
+
+// Calls fn with every indexed key and its value, in the order the keys
+// were first set, until fn returns false.
+// The store must have been written through an IndexedKVStore.
+func IterateKeys(store types.KVStore, fn func(key []byte, value []byte) bool) {
+	count := GetKeyCount(store)
+	for n := uint64(0); n < count; n++ {
+		key := store.Get(indexKey(n))
+		if !fn(key, store.Get(key)) {
+			return
+		}
+	}
+}
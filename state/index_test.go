@@ -0,0 +1,93 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/tendermint/basecoin/types"
+)
+
+func TestIndexedKVStore(t *testing.T) {
+	store := NewIndexedKVStore(types.NewMemKVStore())
+	store.Set([]byte("a"), []byte("1"))
+	store.Set([]byte("b"), []byte("2"))
+	store.Set([]byte("c"), []byte("3"))
+	store.Set([]byte("a"), []byte("4"))
+
+	var keys, values []string
+	IterateKeys(store, func(key []byte, value []byte) bool {
+		keys = append(keys, string(key))
+		values = append(values, string(value))
+		return true
+	})
+	if len(keys) != 3 || keys[0] != "a" || keys[1] != "b" || keys[2] != "c" {
+		t.Fatalf("Expected keys a, b and c in the order first set, got %v", keys)
+	}
+	if values[0] != "4" || values[1] != "2" || values[2] != "3" {
+		t.Errorf("Expected the latest values, got %v", values)
+	}
+	if err := CheckIndexComplete(store); err != nil {
+		t.Errorf("Expected an index started at genesis to be complete: %v", err)
+	}
+}
+
+// A store that can tell a removed key from an empty one.
+type mapKVStore map[string][]byte
+
+func (m mapKVStore) Set(key []byte, value []byte) { m[string(key)] = value }
+func (m mapKVStore) Get(key []byte) []byte        { return m[string(key)] }
+func (m mapKVStore) Remove(key []byte)            { delete(m, string(key)) }
+
+func TestIndexedKVStoreRemove(t *testing.T) {
+	mem := mapKVStore{}
+	store := NewIndexedKVStore(mem)
+	store.Set([]byte("a"), []byte("1"))
+	store.Set([]byte("b"), []byte("2"))
+	store.Set([]byte("c"), []byte("3"))
+	store.Set([]byte("a"), nil)
+	// Removing a key that was never set writes nothing.
+	store.Set([]byte("d"), nil)
+
+	var keys []string
+	IterateKeys(store, func(key []byte, value []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	})
+	// The last key takes the place of the removed one.
+	if len(keys) != 2 || keys[0] != "c" || keys[1] != "b" {
+		t.Fatalf("Expected keys c and b, got %v", keys)
+	}
+	for _, key := range []string{"a", "d", "base/ki/a", "base/keys/2"} {
+		if _, ok := mem[key]; ok {
+			t.Errorf("Expected %v to be deleted from the store", key)
+		}
+	}
+	if err := CheckIndexComplete(store); err != nil {
+		t.Errorf("Expected the index to stay complete: %v", err)
+	}
+}
+
+func TestIndexSkipsCacheReads(t *testing.T) {
+	state := NewState(NewIndexedKVStore(types.NewMemKVStore()))
+	cache := state.CacheWrap()
+	cache.Get([]byte("read"))
+	cache.GetAccount([]byte("missing_address_0000"))
+	cache.Set([]byte("written"), []byte("1"))
+	cache.CacheSync()
+
+	if count := GetKeyCount(state); count != 1 {
+		t.Errorf("Expected only the written key to be indexed, got %v keys", count)
+	}
+}
+
+func TestIndexPredatingState(t *testing.T) {
+	// Keys set before the index existed, then a commit.
+	mem := types.NewMemKVStore()
+	mem.Set([]byte("old"), []byte("1"))
+	SetLastHeight(mem, 5)
+
+	store := NewIndexedKVStore(mem)
+	store.Set([]byte("new"), []byte("2"))
+	if err := CheckIndexComplete(store); err == nil {
+		t.Fatal("Expected an index started after a commit to be incomplete")
+	}
+}
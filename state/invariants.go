@@ -0,0 +1,75 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+)
+
+// Checks that the coins held by all accounts, plugin accounts included,
+// add up to the recorded supply of every denom. Walks every key, so
+// it's meant to run every so many blocks, not every block.
+// Returns an error describing each denom that doesn't add up, or an
+// error if the key index is incomplete and the accounts can't all be found.
+func CheckSupply(store types.KVStore) error {
+	if err := CheckIndexComplete(store); err != nil {
+		return errors.New("Can't check the supply: " + err.Error())
+	}
+	accountPrefix := AccountKey(nil)
+	supplyPrefix := SupplyKey("")
+	held := make(map[string]int64)
+	recorded := make(map[string]int64)
+	accounts := 0
+	var err error
+	IterateKeys(store, func(key []byte, value []byte) bool {
+		if len(value) == 0 {
+			return true
+		}
+		switch {
+		case bytes.HasPrefix(key, accountPrefix):
+			var acc *types.Account
+			if err = wire.ReadBinaryBytes(value, &acc); err != nil {
+				err = errors.New(Fmt("Error reading account at %X: %v", key, err))
+				return false
+			}
+			for _, coin := range acc.Balance {
+				held[coin.Denom] += coin.Amount
+			}
+			accounts += 1
+		case bytes.HasPrefix(key, supplyPrefix):
+			denom := string(key[len(supplyPrefix):])
+			recorded[denom] = GetSupply(store, denom)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	denoms := make([]string, 0, len(held)+len(recorded))
+	for denom := range held {
+		denoms = append(denoms, denom)
+	}
+	for denom := range recorded {
+		if _, ok := held[denom]; !ok {
+			denoms = append(denoms, denom)
+		}
+	}
+	sort.Strings(denoms)
+	var mismatches []string
+	for _, denom := range denoms {
+		if held[denom] != recorded[denom] {
+			mismatches = append(mismatches, Fmt("denom %q has supply %v but %v accounts hold %v",
+				denom, recorded[denom], accounts, held[denom]))
+		}
+	}
+	if len(mismatches) > 0 {
+		return errors.New("Supply invariant broken: " + strings.Join(mismatches, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package state
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tendermint/basecoin/types"
+)
+
+func TestCheckSupply(t *testing.T) {
+	store := NewIndexedKVStore(types.NewMemKVStore())
+	alice := []byte("alice_address_000000")
+	bob := []byte("bob_address_00000000")
+
+	MintCoins(store, alice, types.Coins{{"", 100}, {"gold", 5}})
+	TransferCoins(store, alice, bob, types.Coins{{"", 40}})
+	TransferCoins(store, alice, types.PluginAddress("escrow"), types.Coins{{"gold", 2}})
+	payFee(store, 10)
+	SubtractCoins(store, alice, types.Coins{{"", 10}})
+	if !BurnCoins(store, bob, types.Coins{{"", 15}}) {
+		t.Fatal("Expected burn to succeed")
+	}
+	if err := CheckSupply(store); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Coins that appear without a mint break the invariant.
+	AddCoins(store, bob, types.Coins{{"gold", 1}})
+	if err := CheckSupply(store); err == nil {
+		t.Fatal("Expected coins added outside a mint to break the supply")
+	}
+}
+
+func TestCheckSupplyPredatingState(t *testing.T) {
+	// An account set before the index existed, then a commit.
+	mem := types.NewMemKVStore()
+	MintCoins(mem, []byte("alice_address_000000"), types.Coins{{"", 100}})
+	SetLastHeight(mem, 5)
+
+	store := NewIndexedKVStore(mem)
+	MintCoins(store, []byte("bob_address_00000000"), types.Coins{{"", 10}})
+	err := CheckSupply(store)
+	if err == nil || !strings.Contains(err.Error(), "keys set before then are missing") {
+		t.Fatalf("Expected the check to refuse an incomplete index, got %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package state
+
+import (
+	"strings"
+
+	"github.com/tendermint/basecoin/types"
+)
+
+func enabledPluginsKey() []byte {
+	return []byte("base/plugins")
+}
+
+// Returns the names of the enabled plugins, or nil if all are.
+// The set is part of the state, so every node agrees on it.
+func GetEnabledPlugins(store types.KVStore) []string {
+	data := store.Get(enabledPluginsKey())
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(string(data), ",")
+}
+
+func SetEnabledPlugins(store types.KVStore, names []string) {
+	store.Set(enabledPluginsKey(), []byte(strings.Join(names, ",")))
+}
+
+func IsPluginEnabled(store types.KVStore, name string) bool {
+	enabled := GetEnabledPlugins(store)
+	if len(enabled) == 0 {
+		return true
+	}
+	for _, enabledName := range enabled {
+		if enabledName == name {
+			return true
+		}
+	}
+	return false
+}
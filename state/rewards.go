@@ -0,0 +1,153 @@
+package state
+
+import (
+	"math/big"
+
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+// Parts per billion, the unit of the inflation rate.
+const inflationRateUnit = 1000000000
+
+// Block rewards are minted into this account, then paid out from it.
+var RewardPoolAddress = wire.BinaryRipemd160("base/rewards")
+
+func inflationRateKey() []byte {
+	return []byte("base/inflation_rate")
+}
+
+func inflationDenomKey() []byte {
+	return []byte("base/inflation_denom")
+}
+
+func validatorsKey() []byte {
+	return []byte("base/validators")
+}
+
+// Returns the share of the supply minted every block, in parts per billion.
+func GetInflationRate(store types.KVStore) uint64 {
+	data := store.Get(inflationRateKey())
+	if len(data) == 0 {
+		return 0
+	}
+	var rate uint64
+	err := wire.ReadBinaryBytes(data, &rate)
+	if err != nil {
+		panic(Fmt("Error reading inflation rate %X error: %v",
+			data, err.Error()))
+	}
+	return rate
+}
+
+func SetInflationRate(store types.KVStore, rate uint64) {
+	store.Set(inflationRateKey(), wire.BinaryBytes(rate))
+}
+
+// Returns the denom that inflates.
+func GetInflationDenom(store types.KVStore) string {
+	return string(store.Get(inflationDenomKey()))
+}
+
+func SetInflationDenom(store types.KVStore, denom string) {
+	store.Set(inflationDenomKey(), []byte(denom))
+}
+
+// Returns the validator set, as of the last EndBlock.
+func GetValidators(store types.KVStore) (vals []*tmsp.Validator) {
+	data := store.Get(validatorsKey())
+	if len(data) == 0 {
+		return nil
+	}
+	err := wire.ReadBinaryBytes(data, &vals)
+	if err != nil {
+		panic(Fmt("Error reading validators %X error: %v",
+			data, err.Error()))
+	}
+	return vals
+}
+
+func SetValidators(store types.KVStore, vals []*tmsp.Validator) {
+	store.Set(validatorsKey(), wire.BinaryBytes(vals))
+}
+
+// Applies validator diffs from EndBlock.
+// A diff with zero power removes the validator.
+func UpdateValidators(store types.KVStore, diffs []*tmsp.Validator) {
+	if len(diffs) == 0 {
+		return
+	}
+	vals := GetValidators(store)
+	for _, diff := range diffs {
+		found := false
+		for i, val := range vals {
+			if string(val.PubKey) == string(diff.PubKey) {
+				vals[i] = diff
+				found = true
+				break
+			}
+		}
+		if !found {
+			vals = append(vals, diff)
+		}
+	}
+	updated := vals[:0]
+	for _, val := range vals {
+		if val.Power != 0 {
+			updated = append(updated, val)
+		}
+	}
+	SetValidators(store, updated)
+}
+
+// Mints this block's inflation into the reward pool and pays every
+// denom in the pool, fees included, out to validators in proportion
+// to their power. Whatever doesn't divide evenly stays in the pool.
+func PayBlockReward(store types.KVStore) {
+	denom := GetInflationDenom(store)
+	rate := GetInflationRate(store)
+	if rate != 0 {
+		minted := new(big.Int).SetInt64(GetSupply(store, denom))
+		minted.Mul(minted, new(big.Int).SetUint64(rate))
+		minted.Div(minted, big.NewInt(inflationRateUnit))
+		if minted.Sign() > 0 {
+			MintCoins(store, RewardPoolAddress, types.Coins{{denom, minted.Int64()}})
+		}
+	}
+
+	vals := GetValidators(store)
+	pool := GetAccount(store, RewardPoolAddress)
+	if len(vals) == 0 || pool == nil || len(pool.Balance) == 0 {
+		return
+	}
+	totalPower := new(big.Int)
+	for _, val := range vals {
+		totalPower.Add(totalPower, new(big.Int).SetUint64(val.Power))
+	}
+	for _, val := range vals {
+		var pubKey crypto.PubKey
+		err := wire.ReadBinaryBytes(val.PubKey, &pubKey)
+		if err != nil {
+			// Its share stays in the pool.
+			continue
+		}
+		var reward types.Coins
+		for _, coin := range pool.Balance {
+			share := new(big.Int).SetInt64(coin.Amount)
+			share.Mul(share, new(big.Int).SetUint64(val.Power))
+			share.Div(share, totalPower)
+			if share.Sign() > 0 {
+				reward = append(reward, types.Coin{coin.Denom, share.Int64()})
+			}
+		}
+		if len(reward) == 0 {
+			continue
+		}
+		if !TransferCoins(store, RewardPoolAddress, pubKey.Address(), reward) {
+			PanicSanity(Fmt("Reward pool should hold %v", reward))
+		}
+	}
+}
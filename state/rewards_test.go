@@ -0,0 +1,65 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/tendermint/basecoin/types"
+	"github.com/tendermint/go-crypto"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+func TestPayBlockReward(t *testing.T) {
+	store := NewIndexedKVStore(types.NewMemKVStore())
+	holder := []byte("holder_address_00000")
+	MintCoins(store, holder, types.Coins{{"", 1000000}})
+	SetInflationRate(store, 10000000) // 1%
+
+	val1 := crypto.GenPrivKeyEd25519().PubKey()
+	val2 := crypto.GenPrivKeyEd25519().PubKey()
+	SetValidators(store, []*tmsp.Validator{
+		{PubKey: val1.Bytes(), Power: 1},
+		{PubKey: val2.Bytes(), Power: 3},
+		{PubKey: []byte("not_a_pub_key"), Power: 2},
+	})
+
+	PayBlockReward(store)
+	if supply := GetSupply(store, ""); supply != 1010000 {
+		t.Errorf("Expected 1%% of the supply to be minted, got a supply of %v", supply)
+	}
+	// 10000 minted, split 1:3:2 by power.
+	if acc := GetAccount(store, val1.Address()); acc == nil || !acc.Balance.IsEqual(types.Coins{{"", 1666}}) {
+		t.Errorf("Expected a sixth of the reward, got %v", acc)
+	}
+	if acc := GetAccount(store, val2.Address()); acc == nil || !acc.Balance.IsEqual(types.Coins{{"", 5000}}) {
+		t.Errorf("Expected half of the reward, got %v", acc)
+	}
+	// The undecodable validator's share and the remainder stay in the pool.
+	if acc := GetAccount(store, RewardPoolAddress); !acc.Balance.IsEqual(types.Coins{{"", 3334}}) {
+		t.Errorf("Expected 3334 left in the pool, got %v", acc.Balance)
+	}
+	if err := CheckSupply(store); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestPayBlockRewardPaysEveryDenom(t *testing.T) {
+	store := NewIndexedKVStore(types.NewMemKVStore())
+	SetInflationDenom(store, "atom")
+	AddCoins(store, RewardPoolAddress, types.Coins{{"atom", 30}})
+	payFee(store, 60)
+
+	val1 := crypto.GenPrivKeyEd25519().PubKey()
+	val2 := crypto.GenPrivKeyEd25519().PubKey()
+	SetValidators(store, []*tmsp.Validator{
+		{PubKey: val1.Bytes(), Power: 1},
+		{PubKey: val2.Bytes(), Power: 2},
+	})
+
+	PayBlockReward(store)
+	if acc := GetAccount(store, val1.Address()); acc == nil || !acc.Balance.IsEqual(types.Coins{{"", 20}, {"atom", 10}}) {
+		t.Errorf("Expected a third of the fees and rewards, got %v", acc)
+	}
+	if acc := GetAccount(store, val2.Address()); acc == nil || !acc.Balance.IsEqual(types.Coins{{"", 40}, {"atom", 20}}) {
+		t.Errorf("Expected two thirds of the fees and rewards, got %v", acc)
+	}
+}
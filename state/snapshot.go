@@ -0,0 +1,141 @@
+package state
+
+import (
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+)
+
+/*
+Balance snapshots let plugins read account balances as they were when a
+snapshot was taken. Nothing is copied up front: SetAccount() records the
+old balance of an account the first time it changes while a snapshot is
+active, and accounts without a record haven't changed since.
+
+  - base/snaps                The ids of the active snapshots
+  - base/snap/<id>/<addr>     The balance of addr when id was taken
+  - base/snapaddrs/<id>       The number of addresses recorded for id
+  - base/snapaddrs/<id>/<n>   The nth address recorded for id
+
+Releasing a snapshot removes its records, so they don't stay in the state.
+*/
+
+func snapshotsKey() []byte {
+	return []byte("base/snaps")
+}
+
+func SnapshotKey(id string, addr []byte) []byte {
+	return append([]byte("base/snap/"+id+"/"), addr...)
+}
+
+func snapshotAddrCountKey(id string) []byte {
+	return []byte("base/snapaddrs/" + id)
+}
+
+func snapshotAddrKey(id string, n uint64) []byte {
+	return []byte(Fmt("base/snapaddrs/%v/%v", id, n))
+}
+
+// Starts recording balances for the snapshot id.
+func TakeSnapshot(store types.KVStore, id string) {
+	ids := getSnapshots(store)
+	for _, other := range ids {
+		if other == id {
+			PanicSanity("Snapshot already taken: " + id)
+		}
+	}
+	store.Set(snapshotsKey(), wire.BinaryBytes(append(ids, id)))
+}
+
+// Stops recording balances for the snapshot id and removes its records.
+// Its balances can no longer be read afterwards.
+func ReleaseSnapshot(store types.KVStore, id string) {
+	ids := getSnapshots(store)
+	for i, other := range ids {
+		if other == id {
+			ids = append(ids[:i], ids[i+1:]...)
+			store.Set(snapshotsKey(), wire.BinaryBytes(ids))
+			removeSnapshotRecords(store, id)
+			return
+		}
+	}
+}
+
+func removeSnapshotRecords(store types.KVStore, id string) {
+	count := getSnapshotAddrCount(store, id)
+	for n := uint64(0); n < count; n++ {
+		addr := store.Get(snapshotAddrKey(id, n))
+		store.Set(SnapshotKey(id, addr), nil)
+		store.Set(snapshotAddrKey(id, n), nil)
+	}
+	store.Set(snapshotAddrCountKey(id), nil)
+}
+
+// Returns the balance addr had when the snapshot id was taken.
+func GetSnapshotBalance(store types.KVStore, id string, addr []byte) types.Coins {
+	data := store.Get(SnapshotKey(id, addr))
+	if len(data) == 0 {
+		// Unchanged since the snapshot.
+		if acc := GetAccount(store, addr); acc != nil {
+			return acc.Balance
+		}
+		return nil
+	}
+	var balance types.Coins
+	err := wire.ReadBinaryBytes(data, &balance)
+	if err != nil {
+		panic(Fmt("Error reading snapshot balance %X error: %v",
+			data, err.Error()))
+	}
+	return balance
+}
+
+// Records the current balance of addr in every active snapshot
+// that doesn't have one yet. Called before the account changes.
+func recordSnapshots(store types.KVStore, addr []byte) {
+	ids := getSnapshots(store)
+	if len(ids) == 0 {
+		return
+	}
+	var balance types.Coins
+	if acc := GetAccount(store, addr); acc != nil {
+		balance = acc.Balance
+	}
+	for _, id := range ids {
+		key := SnapshotKey(id, addr)
+		// An encoded balance is never empty, even with no coins.
+		if len(store.Get(key)) == 0 {
+			store.Set(key, wire.BinaryBytes(balance))
+			n := getSnapshotAddrCount(store, id)
+			store.Set(snapshotAddrKey(id, n), addr)
+			store.Set(snapshotAddrCountKey(id), wire.BinaryBytes(n+1))
+		}
+	}
+}
+
+func getSnapshotAddrCount(store types.KVStore, id string) uint64 {
+	data := store.Get(snapshotAddrCountKey(id))
+	if len(data) == 0 {
+		return 0
+	}
+	var count uint64
+	err := wire.ReadBinaryBytes(data, &count)
+	if err != nil {
+		panic(Fmt("Error reading snapshot address count %X error: %v",
+			data, err.Error()))
+	}
+	return count
+}
+
+func getSnapshots(store types.KVStore) (ids []string) {
+	data := store.Get(snapshotsKey())
+	if len(data) == 0 {
+		return nil
+	}
+	err := wire.ReadBinaryBytes(data, &ids)
+	if err != nil {
+		panic(Fmt("Error reading snapshots %X error: %v",
+			data, err.Error()))
+	}
+	return ids
+}
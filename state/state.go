@@ -10,19 +10,34 @@ import (
 // See CacheWrap().
 type State struct {
 	chainID string
+	height  uint64
 	store   types.KVStore
 	cache   *types.KVCache // optional
 }
 
+// Picks up the chain ID if store already has one.
 func NewState(store types.KVStore) *State {
 	return &State{
-		chainID: "",
+		chainID: string(store.Get(ChainIDKey())),
 		store:   store,
 	}
 }
 
+func ChainIDKey() []byte {
+	return []byte("base/chain_id")
+}
+
+// Returns the chain ID saved by SetChainID, for plugins, which only
+// get a store.
+func GetChainID(store types.KVStore) string {
+	return string(store.Get(ChainIDKey()))
+}
+
+// Sets the chain ID and saves it in the store, so it's
+// known after a restart without replaying genesis.
 func (s *State) SetChainID(chainID string) {
 	s.chainID = chainID
+	s.store.Set(ChainIDKey(), []byte(chainID))
 }
 
 func (s *State) GetChainID() string {
@@ -32,6 +47,15 @@ func (s *State) GetChainID() string {
 	return s.chainID
 }
 
+// Sets the height of the block being executed.
+func (s *State) SetBlockHeight(height uint64) {
+	s.height = height
+}
+
+func (s *State) GetBlockHeight() uint64 {
+	return s.height
+}
+
 func (s *State) Get(key []byte) (value []byte) {
 	return s.store.Get(key)
 }
@@ -48,15 +72,30 @@ func (s *State) SetAccount(addr []byte, acc *types.Account) {
 	SetAccount(s.store, addr, acc)
 }
 
+// Returns the coins of addr that a vesting schedule keeps from being spent.
+func (s *State) GetLockedCoins(addr []byte) types.Coins {
+	schedule := GetVestingSchedule(s.store, addr)
+	if schedule == nil {
+		return nil
+	}
+	return schedule.Locked(s.height)
+}
+
 func (s *State) CacheWrap() *State {
 	cache := types.NewKVCache(s.store)
 	return &State{
 		chainID: s.chainID,
+		height:  s.height,
 		store:   cache,
 		cache:   cache,
 	}
 }
 
+// NOTE: errors if s is not from CacheWrap()
+func (s *State) CacheStats() (hits, misses uint64) {
+	return s.cache.Stats()
+}
+
 // NOTE: errors if s is not from CacheWrap()
 func (s *State) CacheSync() {
 	s.cache.Sync()
@@ -83,6 +122,70 @@ func GetAccount(store types.KVStore, addr []byte) *types.Account {
 }
 
 func SetAccount(store types.KVStore, addr []byte, acc *types.Account) {
+	recordSnapshots(store, addr)
 	accBytes := wire.BinaryBytes(acc)
 	store.Set(AccountKey(addr), accBytes)
 }
+
+func VestingScheduleKey(addr []byte) []byte {
+	return append([]byte("base/vest/"), addr...)
+}
+
+func GetVestingSchedule(store types.KVStore, addr []byte) *types.VestingSchedule {
+	data := store.Get(VestingScheduleKey(addr))
+	if len(data) == 0 {
+		return nil
+	}
+	var schedule *types.VestingSchedule
+	err := wire.ReadBinaryBytes(data, &schedule)
+	if err != nil {
+		panic(Fmt("Error reading vesting schedule %X error: %v",
+			data, err.Error()))
+	}
+	return schedule
+}
+
+func SetVestingSchedule(store types.KVStore, addr []byte, schedule *types.VestingSchedule) {
+	store.Set(VestingScheduleKey(addr), wire.BinaryBytes(schedule))
+}
+
+// Adds coins to the account at addr, creating the account if necessary.
+func AddCoins(store types.KVStore, addr []byte, coins types.Coins) {
+	if coins.IsZero() {
+		return
+	}
+	acc := GetAccount(store, addr)
+	if acc == nil {
+		acc = &types.Account{
+			PubKey:   nil,
+			Sequence: 0,
+		}
+	}
+	acc.Balance = acc.Balance.Plus(coins)
+	SetAccount(store, addr, acc)
+}
+
+// Subtracts coins from the account at addr.
+// Returns false, leaving the account untouched, if the balance is insufficient.
+func SubtractCoins(store types.KVStore, addr []byte, coins types.Coins) bool {
+	if coins.IsZero() {
+		return true
+	}
+	acc := GetAccount(store, addr)
+	if acc == nil || !acc.Balance.IsGTE(coins) {
+		return false
+	}
+	acc.Balance = acc.Balance.Minus(coins)
+	SetAccount(store, addr, acc)
+	return true
+}
+
+// Moves coins from one account to another.
+// Returns false, leaving both accounts untouched, if from has insufficient funds.
+func TransferCoins(store types.KVStore, from, to []byte, coins types.Coins) bool {
+	if !SubtractCoins(store, from, coins) {
+		return false
+	}
+	AddCoins(store, to, coins)
+	return true
+}
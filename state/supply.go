@@ -0,0 +1,69 @@
+package state
+
+import (
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+)
+
+// The total supply of each denom is kept in state, starting
+// from the genesis balances, so inflation can be computed
+// without walking every account. After genesis, only MintCoins
+// and BurnCoins change it; CheckSupply verifies the accounts
+// still add up to it.
+
+func SupplyKey(denom string) []byte {
+	return []byte("base/supply/" + denom)
+}
+
+func GetSupply(store types.KVStore, denom string) int64 {
+	data := store.Get(SupplyKey(denom))
+	if len(data) == 0 {
+		return 0
+	}
+	var supply int64
+	err := wire.ReadBinaryBytes(data, &supply)
+	if err != nil {
+		panic(Fmt("Error reading supply %X error: %v",
+			data, err.Error()))
+	}
+	return supply
+}
+
+func setSupply(store types.KVStore, denom string, supply int64) {
+	store.Set(SupplyKey(denom), wire.BinaryBytes(supply))
+}
+
+// Adds coins to the total supply.
+func AddSupply(store types.KVStore, coins types.Coins) {
+	for _, coin := range coins {
+		setSupply(store, coin.Denom, GetSupply(store, coin.Denom)+coin.Amount)
+	}
+}
+
+// Removes coins from the total supply.
+func SubtractSupply(store types.KVStore, coins types.Coins) {
+	for _, coin := range coins {
+		supply := GetSupply(store, coin.Denom) - coin.Amount
+		if supply < 0 {
+			PanicSanity(Fmt("Supply of %v would go negative", coin.Denom))
+		}
+		setSupply(store, coin.Denom, supply)
+	}
+}
+
+// Creates coins in the account at addr.
+func MintCoins(store types.KVStore, addr []byte, coins types.Coins) {
+	AddSupply(store, coins)
+	AddCoins(store, addr, coins)
+}
+
+// Destroys coins in the account at addr.
+// Returns false, leaving the account untouched, if the balance is insufficient.
+func BurnCoins(store types.KVStore, addr []byte, coins types.Coins) bool {
+	if !SubtractCoins(store, addr, coins) {
+		return false
+	}
+	SubtractSupply(store, coins)
+	return true
+}
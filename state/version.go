@@ -0,0 +1,54 @@
+package state
+
+import (
+	"github.com/tendermint/basecoin/types"
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+)
+
+func PluginVersionKey(name string) []byte {
+	return append([]byte("base/v/"), name...)
+}
+
+// Returns the state schema version recorded for the named plugin,
+// or 0 if none was ever recorded.
+func GetPluginVersion(store types.KVStore, name string) int {
+	data := store.Get(PluginVersionKey(name))
+	if len(data) == 0 {
+		return 0
+	}
+	var version int
+	err := wire.ReadBinaryBytes(data, &version)
+	if err != nil {
+		panic(Fmt("Error reading plugin version %X error: %v",
+			data, err.Error()))
+	}
+	return version
+}
+
+func SetPluginVersion(store types.KVStore, name string, version int) {
+	store.Set(PluginVersionKey(name), wire.BinaryBytes(version))
+}
+
+func lastHeightKey() []byte {
+	return []byte("base/height")
+}
+
+// Returns the height of the last committed block, or 0 before the first.
+func GetLastHeight(store types.KVStore) uint64 {
+	data := store.Get(lastHeightKey())
+	if len(data) == 0 {
+		return 0
+	}
+	var height uint64
+	err := wire.ReadBinaryBytes(data, &height)
+	if err != nil {
+		panic(Fmt("Error reading last height %X error: %v",
+			data, err.Error()))
+	}
+	return height
+}
+
+func SetLastHeight(store types.KVStore, height uint64) {
+	store.Set(lastHeightKey(), wire.BinaryBytes(height))
+}
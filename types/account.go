@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"math/big"
 
 	"github.com/tendermint/go-crypto"
 )
@@ -27,6 +28,64 @@ func (acc *Account) String() string {
 
 //----------------------------------------
 
+// A VestingSchedule locks Coins of an account until EndHeight.
+// With Cliff they all unlock at once, otherwise they unlock linearly
+// from StartHeight on.
+type VestingSchedule struct {
+	StartHeight uint64 `json:"start_height"`
+	EndHeight   uint64 `json:"end_height"`
+	Cliff       bool   `json:"cliff"`
+	Coins       Coins  `json:"coins"`
+}
+
+// Returns the coins that are still locked at height.
+func (vs *VestingSchedule) Locked(height uint64) Coins {
+	switch {
+	case height >= vs.EndHeight:
+		return nil
+	case height < vs.StartHeight || vs.Cliff:
+		return vs.Coins
+	}
+	// Compute amount * (end - height) / (end - start) without overflowing.
+	remaining := new(big.Int).SetUint64(vs.EndHeight - height)
+	total := new(big.Int).SetUint64(vs.EndHeight - vs.StartHeight)
+	locked := Coins{}
+	for _, coin := range vs.Coins {
+		amount := new(big.Int).Mul(big.NewInt(coin.Amount), remaining)
+		amount.Div(amount, total)
+		if amount.Sign() != 0 {
+			locked = append(locked, Coin{coin.Denom, amount.Int64()})
+		}
+	}
+	return locked
+}
+
+func (vs *VestingSchedule) ValidateBasic() error {
+	if vs.EndHeight < vs.StartHeight {
+		return fmt.Errorf("End height %v is before start height %v", vs.EndHeight, vs.StartHeight)
+	}
+	if !vs.Coins.IsValid() || !vs.Coins.IsPositive() {
+		return fmt.Errorf("Invalid coins %v", vs.Coins)
+	}
+	return nil
+}
+
+// A VestingAccount is an Account whose Balance is partly locked
+// by a Schedule. The schedule is stored apart from the account.
+type VestingAccount struct {
+	Account  *Account         `json:"account"`
+	Schedule *VestingSchedule `json:"schedule"`
+}
+
+// AccountBalance is the result of a base account query.
+type AccountBalance struct {
+	Account   *Account `json:"account"`
+	Locked    Coins    `json:"locked"`
+	Spendable Coins    `json:"spendable"`
+}
+
+//----------------------------------------
+
 type PrivAccount struct {
 	crypto.PrivKey
 	Account
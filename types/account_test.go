@@ -0,0 +1,42 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestVestingScheduleLinear(t *testing.T) {
+	schedule := &VestingSchedule{
+		StartHeight: 100,
+		EndHeight:   200,
+		Coins:       Coins{{"", 1000}, {"GAS", 10}},
+	}
+
+	if locked := schedule.Locked(50); !locked.IsEqual(schedule.Coins) {
+		t.Errorf("Expected everything locked before start, got %v", locked)
+	}
+	if locked := schedule.Locked(150); !locked.IsEqual(Coins{{"", 500}, {"GAS", 5}}) {
+		t.Errorf("Expected half locked halfway, got %v", locked)
+	}
+	if locked := schedule.Locked(199); !locked.IsEqual(Coins{{"", 10}}) {
+		t.Errorf("Expected zero amounts to be dropped, got %v", locked)
+	}
+	if locked := schedule.Locked(200); !locked.IsZero() {
+		t.Errorf("Expected nothing locked at end, got %v", locked)
+	}
+}
+
+func TestVestingScheduleCliff(t *testing.T) {
+	schedule := &VestingSchedule{
+		StartHeight: 100,
+		EndHeight:   200,
+		Cliff:       true,
+		Coins:       Coins{{"", 1000}},
+	}
+
+	if locked := schedule.Locked(199); !locked.IsEqual(schedule.Coins) {
+		t.Errorf("Expected everything locked before the cliff, got %v", locked)
+	}
+	if locked := schedule.Locked(200); !locked.IsZero() {
+		t.Errorf("Expected nothing locked after the cliff, got %v", locked)
+	}
+}
@@ -0,0 +1,18 @@
+package types
+
+import (
+	"time"
+
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+// Fired by ExecTx after a plugin runs an AppTx.
+func EventStringRunTx(pluginName string) string {
+	return "RunTx/" + pluginName
+}
+
+type EventDataRunTx struct {
+	Plugin   string
+	Duration time.Duration
+	Code     tmsp.CodeType
+}
@@ -12,6 +12,12 @@ type KVStore interface {
 	Get(key []byte) (value []byte)
 }
 
+// A KVRemover can delete a key outright, rather than set it empty.
+// The MerkleEyes client is one.
+type KVRemover interface {
+	Remove(key []byte)
+}
+
 //----------------------------------------
 
 type MemKVStore struct {
@@ -32,18 +38,25 @@ func (mkv *MemKVStore) Get(key []byte) (value []byte) {
 	return mkv.m[string(key)]
 }
 
+func (mkv *MemKVStore) Remove(key []byte) {
+	delete(mkv.m, string(key))
+}
+
 //----------------------------------------
 
 // A Cache that enforces deterministic sync order.
 type KVCache struct {
-	store KVStore
-	cache map[string]kvCacheValue
-	keys  *list.List
+	store  KVStore
+	cache  map[string]kvCacheValue
+	keys   *list.List
+	hits   uint64
+	misses uint64
 }
 
 type kvCacheValue struct {
-	v []byte        // The value of some key
-	e *list.Element // The KVCache.keys element
+	v     []byte        // The value of some key
+	e     *list.Element // The KVCache.keys element
+	dirty bool          // Whether v was Set, rather than read
 }
 
 func NewKVCache(store KVStore) *KVCache {
@@ -67,6 +80,7 @@ func (kvc *KVCache) Set(key []byte, value []byte) {
 		cacheValue.e = kvc.keys.PushBack(key)
 	}
 	cacheValue.v = value
+	cacheValue.dirty = true
 	kvc.cache[string(key)] = cacheValue
 }
 
@@ -74,6 +88,7 @@ func (kvc *KVCache) Get(key []byte) (value []byte) {
 	cacheValue, ok := kvc.cache[string(key)]
 	if ok {
 		fmt.Println("GET [KVCache, hit]", formatBytes(key), "=", formatBytes(cacheValue.v))
+		kvc.hits += 1
 		return cacheValue.v
 	} else {
 		value := kvc.store.Get(key)
@@ -82,15 +97,26 @@ func (kvc *KVCache) Get(key []byte) (value []byte) {
 			e: kvc.keys.PushBack(key),
 		}
 		fmt.Println("GET [KVCache, miss]", formatBytes(key), "=", formatBytes(value))
+		kvc.misses += 1
 		return value
 	}
 }
 
+// Returns how many Gets were answered from the cache, and how many
+// went to the underlying store, since the cache was made.
+func (kvc *KVCache) Stats() (hits, misses uint64) {
+	return kvc.hits, kvc.misses
+}
+
+// Writes back the keys that were Set, in order. Keys that were only read
+// are left alone, so the store sees no write it didn't get asked for.
 func (kvc *KVCache) Sync() {
 	for e := kvc.keys.Front(); e != nil; e = e.Next() {
 		key := e.Value.([]byte)
 		value := kvc.cache[string(key)]
-		kvc.store.Set(key, value.v)
+		if value.dirty {
+			kvc.store.Set(key, value.v)
+		}
 	}
 	kvc.Reset()
 }
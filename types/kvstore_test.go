@@ -0,0 +1,41 @@
+package types
+
+import (
+	"testing"
+)
+
+// A store that records the keys set on it, in order.
+type recordingKVStore struct {
+	*MemKVStore
+	sets []string
+}
+
+func (rkv *recordingKVStore) Set(key []byte, value []byte) {
+	rkv.sets = append(rkv.sets, string(key))
+	rkv.MemKVStore.Set(key, value)
+}
+
+func TestKVCacheSyncWritesOnlySetKeys(t *testing.T) {
+	store := &recordingKVStore{MemKVStore: NewMemKVStore()}
+	store.MemKVStore.Set([]byte("read"), []byte("1"))
+
+	cache := NewKVCache(store)
+	cache.Get([]byte("read"))
+	cache.Get([]byte("missing"))
+	cache.Set([]byte("b"), []byte("2"))
+	cache.Set([]byte("a"), []byte("3"))
+	cache.Get([]byte("b"))
+	cache.Sync()
+
+	// Reads, hits or misses, are never written back.
+	// The app hash depends on this write set, so it must not change.
+	expected := []string{"b", "a"}
+	if len(store.sets) != len(expected) {
+		t.Fatalf("Expected sets %v, got %v", expected, store.sets)
+	}
+	for i, key := range expected {
+		if store.sets[i] != key {
+			t.Fatalf("Expected sets %v, got %v", expected, store.sets)
+		}
+	}
+}
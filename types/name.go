@@ -0,0 +1,39 @@
+package types
+
+import (
+	"bytes"
+)
+
+/*
+A TxOutput can pay a name registered with a NameResolver plugin instead
+of an address. Its Address is then a name reference: '@', then the name
+padded with zeros to 32 bytes. ExecTx resolves it to the owner's address.
+
+A name reference is never 20 bytes long, so it can't be mistaken for an
+address, and outputs to addresses encode and sign just as they always have.
+*/
+
+const (
+	nameRefPrefix = byte('@')
+	nameRefLength = 33
+)
+
+// Returns the output address that refers to name,
+// or nil if the name is too long to refer to.
+func NameRef(name string) []byte {
+	if len(name) == 0 || len(name) > nameRefLength-1 {
+		return nil
+	}
+	ref := make([]byte, nameRefLength)
+	ref[0] = nameRefPrefix
+	copy(ref[1:], name)
+	return ref
+}
+
+// Returns the name that addr refers to, if addr is a name reference.
+func RefName(addr []byte) (name string, ok bool) {
+	if len(addr) != nameRefLength || addr[0] != nameRefPrefix {
+		return "", false
+	}
+	return string(bytes.TrimRight(addr[1:], "\x00")), true
+}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"github.com/tendermint/go-wire"
 	tmsp "github.com/tendermint/tmsp/types"
 )
 
@@ -12,14 +13,56 @@ type Plugin interface {
 	EndBlock(store KVStore, height uint64) []*tmsp.Validator
 }
 
+// A Plugin may also implement TxChecker to validate AppTx data during
+// CheckTx. The store is a throwaway cache of the check-state, so CheckTx
+// must not expect any of its writes to persist.
+type TxChecker interface {
+	CheckTx(store KVStore, ctx CallContext, txBytes []byte) (res tmsp.Result)
+}
+
+// A Plugin may implement Querier to answer TMSP queries whose first
+// byte is the plugin's type byte. The type byte is stripped from query.
+type Querier interface {
+	Query(store KVStore, query []byte) (res tmsp.Result)
+}
+
+// A Plugin may implement NameResolver to let SendTx outputs pay to
+// registered names. ResolveName returns nil for unregistered names.
+type NameResolver interface {
+	ResolveName(store KVStore, name string) (addr []byte)
+}
+
+// A Plugin may implement Committer to be notified right before the state
+// is committed at the end of each block.
+type Committer interface {
+	Commit(store KVStore)
+}
+
+// A Plugin may implement Versioner to declare the version of its state
+// schema. Plugins that don't are at version 0.
+type Versioner interface {
+	Version() int
+}
+
+// A Migration upgrades a plugin's state by exactly one version.
+type Migration func(store KVStore) error
+
 type NamedPlugin struct {
 	Byte byte
 	Name string
 	Plugin
 }
 
+// Returns the address of the account that holds coins for the named plugin.
+// Nobody has the private key for it, so only the plugin can move its coins.
+func PluginAddress(name string) []byte {
+	return wire.BinaryRipemd160("plugin/" + name)
+}
+
 //----------------------------------------
 
+// By the time RunTx is called, Coins have already been moved from
+// the Caller into the plugin's account. See PluginAddress().
 type CallContext struct {
 	Caller []byte
 	Coins  Coins
@@ -37,6 +80,7 @@ func NewCallContext(caller []byte, coins Coins) CallContext {
 type Plugins struct {
 	byByte map[byte]Plugin
 	byName map[string]Plugin
+	names  map[byte]string
 	plist  []NamedPlugin
 }
 
@@ -44,12 +88,14 @@ func NewPlugins() *Plugins {
 	return &Plugins{
 		byByte: make(map[byte]Plugin),
 		byName: make(map[string]Plugin),
+		names:  make(map[byte]string),
 	}
 }
 
 func (pgz *Plugins) RegisterPlugin(typeByte byte, name string, plugin Plugin) {
 	pgz.byByte[typeByte] = plugin
 	pgz.byName[name] = plugin
+	pgz.names[typeByte] = name
 	pgz.plist = append(pgz.plist, NamedPlugin{
 		Byte:   typeByte,
 		Name:   name,
@@ -65,6 +111,10 @@ func (pgz *Plugins) GetByName(name string) Plugin {
 	return pgz.byName[name]
 }
 
+func (pgz *Plugins) GetNameByByte(typeByte byte) string {
+	return pgz.names[typeByte]
+}
+
 func (pgz *Plugins) GetList() []NamedPlugin {
 	return pgz.plist
 }
@@ -78,12 +78,21 @@ func (txIn TxInput) String() string {
 //-----------------------------------------------------------------------------
 
 type TxOutput struct {
-	Address []byte `json:"address"` // Hash of the PubKey
+	Address []byte `json:"address"` // Hash of the PubKey, or a NameRef
 	Coins   Coins  `json:"coins"`   //
 }
 
+// Returns the registered name the output pays, if it pays one.
+func (txOut TxOutput) Name() (name string, ok bool) {
+	return RefName(txOut.Address)
+}
+
 func (txOut TxOutput) ValidateBasic() tmsp.Result {
-	if len(txOut.Address) != 20 {
+	if name, ok := txOut.Name(); ok {
+		if name == "" {
+			return tmsp.ErrBaseInvalidOutput.AppendLog("Empty name")
+		}
+	} else if len(txOut.Address) != 20 {
 		return tmsp.ErrBaseInvalidOutput.AppendLog("Invalid address length")
 	}
 	if !txOut.Coins.IsValid() {
@@ -96,6 +105,9 @@ func (txOut TxOutput) ValidateBasic() tmsp.Result {
 }
 
 func (txOut TxOutput) String() string {
+	if name, ok := txOut.Name(); ok {
+		return Fmt("TxOutput{@%v,%v}", name, txOut.Coins)
+	}
 	return Fmt("TxOutput{%X,%v}", txOut.Address, txOut.Coins)
 }
 
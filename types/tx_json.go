@@ -0,0 +1,267 @@
+package types
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-wire"
+)
+
+/*
+The canonical JSON form of a Tx is meant for people and other languages.
+Byte fields are upper case hex, and keys carry their type by name, or
+as "0x" and the hex type byte for a type without a name:
+
+  {
+    "type": "send",
+    "fee": 0,
+    "gas": 0,
+    "inputs": [{
+      "address": "D9B7...",
+      "coins": [{"denom": "", "amount": 10}],
+      "sequence": 1,
+      "signature": {"type": "ed25519", "data": "61C4..."},
+      "pub_key": {"type": "ed25519", "data": "67D3..."}
+    }],
+    "outputs": [{"address": "5A1D...", "coins": [...]}, {"name": "alice", "coins": [...]}]
+  }
+
+An output that pays a registered name has its "name" instead of an "address".
+
+An AppTx has "type": "app", an "app_type", a single "input" and hex "data".
+Decoding it gives back a Tx with the same binary encoding, and so the same TxID.
+*/
+
+const (
+	txJSONTypeSend = "send"
+	txJSONTypeApp  = "app"
+)
+
+// Names of the key and signature types, by their go-wire type byte.
+var keyTypeNames = map[byte]string{
+	0x01: "ed25519",
+	0x02: "secp256k1",
+}
+
+type txJSON struct {
+	Type    string         `json:"type"`
+	Fee     int64          `json:"fee"`
+	Gas     int64          `json:"gas"`
+	Inputs  []txInputJSON  `json:"inputs,omitempty"`   // SendTx
+	Outputs []txOutputJSON `json:"outputs,omitempty"`  // SendTx
+	AppType *byte          `json:"app_type,omitempty"` // AppTx
+	Input   *txInputJSON   `json:"input,omitempty"`    // AppTx
+	Data    *hexBytes      `json:"data,omitempty"`     // AppTx
+}
+
+type txInputJSON struct {
+	Address   hexBytes `json:"address"`
+	Coins     Coins    `json:"coins"`
+	Sequence  int      `json:"sequence"`
+	Signature *keyJSON `json:"signature"`
+	PubKey    *keyJSON `json:"pub_key"`
+}
+
+type txOutputJSON struct {
+	Address hexBytes `json:"address,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Coins   Coins    `json:"coins"`
+}
+
+// A PubKey or Signature: its type name and the hex of its
+// go-wire encoding without the type byte.
+type keyJSON struct {
+	Type string   `json:"type"`
+	Data hexBytes `json:"data"`
+}
+
+type hexBytes []byte
+
+func (hb hexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.ToUpper(hex.EncodeToString(hb)))
+}
+
+func (hb *hexBytes) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	bz, err := hex.DecodeString(str)
+	if err != nil {
+		return err
+	}
+	*hb = bz
+	return nil
+}
+
+// Returns the canonical JSON form of tx.
+func TxToJSON(tx Tx) ([]byte, error) {
+	var tj txJSON
+	switch tx := tx.(type) {
+	case *SendTx:
+		tj = txJSON{
+			Type: txJSONTypeSend,
+			Fee:  tx.Fee,
+			Gas:  tx.Gas,
+		}
+		for _, input := range tx.Inputs {
+			tj.Inputs = append(tj.Inputs, inputToJSON(input))
+		}
+		for _, output := range tx.Outputs {
+			oj := txOutputJSON{Coins: output.Coins}
+			if name, ok := output.Name(); ok {
+				oj.Name = name
+			} else {
+				oj.Address = output.Address
+			}
+			tj.Outputs = append(tj.Outputs, oj)
+		}
+	case *AppTx:
+		input := inputToJSON(tx.Input)
+		data := hexBytes(tx.Data)
+		appType := tx.Type
+		tj = txJSON{
+			Type:    txJSONTypeApp,
+			Fee:     tx.Fee,
+			Gas:     tx.Gas,
+			AppType: &appType,
+			Input:   &input,
+			Data:    &data,
+		}
+	default:
+		return nil, errors.New(Fmt("Unknown tx type %T", tx))
+	}
+	return json.Marshal(tj)
+}
+
+// Parses the canonical JSON form of a tx.
+func TxFromJSON(data []byte) (Tx, error) {
+	var tj txJSON
+	if err := json.Unmarshal(data, &tj); err != nil {
+		return nil, errors.New("Error decoding tx JSON: " + err.Error())
+	}
+	switch tj.Type {
+	case txJSONTypeSend:
+		tx := &SendTx{
+			Fee: tj.Fee,
+			Gas: tj.Gas,
+		}
+		for _, ij := range tj.Inputs {
+			input, err := inputFromJSON(ij)
+			if err != nil {
+				return nil, err
+			}
+			tx.Inputs = append(tx.Inputs, input)
+		}
+		for _, oj := range tj.Outputs {
+			output := TxOutput{
+				Address: oj.Address,
+				Coins:   oj.Coins,
+			}
+			if oj.Name != "" {
+				if len(oj.Address) != 0 {
+					return nil, errors.New("An output can't have both an address and a name")
+				}
+				output.Address = NameRef(oj.Name)
+			}
+			tx.Outputs = append(tx.Outputs, output)
+		}
+		return tx, nil
+	case txJSONTypeApp:
+		if tj.AppType == nil || tj.Input == nil {
+			return nil, errors.New("AppTx JSON must have an app_type and an input")
+		}
+		input, err := inputFromJSON(*tj.Input)
+		if err != nil {
+			return nil, err
+		}
+		tx := &AppTx{
+			Fee:   tj.Fee,
+			Gas:   tj.Gas,
+			Type:  *tj.AppType,
+			Input: input,
+		}
+		if tj.Data != nil {
+			tx.Data = *tj.Data
+		}
+		return tx, nil
+	}
+	return nil, errors.New("Unknown tx JSON type " + tj.Type)
+}
+
+func inputToJSON(input TxInput) txInputJSON {
+	ij := txInputJSON{
+		Address:  input.Address,
+		Coins:    input.Coins,
+		Sequence: input.Sequence,
+	}
+	if input.Signature != nil {
+		ij.Signature = keyToJSON(input.Signature.Bytes())
+	}
+	if input.PubKey != nil {
+		ij.PubKey = keyToJSON(input.PubKey.Bytes())
+	}
+	return ij
+}
+
+func inputFromJSON(ij txInputJSON) (TxInput, error) {
+	input := TxInput{
+		Address:  ij.Address,
+		Coins:    ij.Coins,
+		Sequence: ij.Sequence,
+	}
+	if ij.Signature != nil {
+		bz, err := keyFromJSON(ij.Signature)
+		if err != nil {
+			return TxInput{}, err
+		}
+		if err := wire.ReadBinaryBytes(bz, &input.Signature); err != nil {
+			return TxInput{}, errors.New("Error decoding signature: " + err.Error())
+		}
+	}
+	if ij.PubKey != nil {
+		bz, err := keyFromJSON(ij.PubKey)
+		if err != nil {
+			return TxInput{}, err
+		}
+		if err := wire.ReadBinaryBytes(bz, &input.PubKey); err != nil {
+			return TxInput{}, errors.New("Error decoding pub key: " + err.Error())
+		}
+	}
+	return input, nil
+}
+
+// Splits the go-wire encoding of a PubKey or Signature into its type and data.
+func keyToJSON(bz []byte) *keyJSON {
+	name, ok := keyTypeNames[bz[0]]
+	if !ok {
+		name = Fmt("0x%02X", bz[0])
+	}
+	return &keyJSON{
+		Type: name,
+		Data: bz[1:],
+	}
+}
+
+// Returns the go-wire encoding of a PubKey or Signature.
+// A type without a name is its type byte in the "0x" form keyToJSON gives it.
+func keyFromJSON(kj *keyJSON) ([]byte, error) {
+	for typeByte, name := range keyTypeNames {
+		if name == kj.Type {
+			return append([]byte{typeByte}, kj.Data...), nil
+		}
+	}
+	if strings.HasPrefix(kj.Type, "0x") {
+		typeByte, err := hex.DecodeString(kj.Type[2:])
+		if err == nil && len(typeByte) == 1 {
+			// Named types have only the one form, so the JSON stays canonical.
+			if _, named := keyTypeNames[typeByte[0]]; !named {
+				return append(typeByte, kj.Data...), nil
+			}
+		}
+	}
+	return nil, errors.New("Unknown key type " + kj.Type)
+}
@@ -0,0 +1,100 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+)
+
+func testTxJSONRoundTrip(t *testing.T, tx Tx) {
+	txJSON, err := TxToJSON(tx)
+	if err != nil {
+		t.Fatalf("Unexpected error encoding %v: %v", tx, err)
+	}
+	decoded, err := TxFromJSON(txJSON)
+	if err != nil {
+		t.Fatalf("Unexpected error decoding %s: %v", txJSON, err)
+	}
+	txBytes := wire.BinaryBytes(struct{ Tx }{tx})
+	decodedBytes := wire.BinaryBytes(struct{ Tx }{decoded})
+	if !bytes.Equal(txBytes, decodedBytes) {
+		t.Errorf("Expected binary %X, got %X from %s", txBytes, decodedBytes, txJSON)
+	}
+	if !bytes.Equal(TxID(chainID, tx), TxID(chainID, decoded)) {
+		t.Errorf("Expected the same TxID after decoding %s", txJSON)
+	}
+	if again, _ := TxToJSON(decoded); !bytes.Equal(txJSON, again) {
+		t.Errorf("Expected canonical JSON %s, got %s", txJSON, again)
+	}
+}
+
+func TestSendTxJSON(t *testing.T) {
+	privKey := crypto.GenPrivKeyEd25519()
+	sendTx := &SendTx{
+		Fee: 1,
+		Gas: 2,
+		Inputs: []TxInput{
+			TxInput{
+				Address:  privKey.PubKey().Address(),
+				Coins:    Coins{{"", 12}, {"mycoin", 3}},
+				Sequence: 1,
+				PubKey:   privKey.PubKey(),
+			},
+			TxInput{
+				Address:  []byte("input2_address_00000"),
+				Coins:    Coins{{"", 111}},
+				Sequence: 222,
+			},
+		},
+		Outputs: []TxOutput{
+			TxOutput{
+				Address: []byte("output1_address_0000"),
+				Coins:   Coins{{"", 122}, {"mycoin", 3}},
+			},
+			TxOutput{
+				Address: NameRef("alice"),
+				Coins:   Coins{{"", 1}},
+			},
+		},
+	}
+	sendTx.SetSignature(privKey.PubKey().Address(), privKey.Sign(sendTx.SignBytes(chainID)))
+	testTxJSONRoundTrip(t, sendTx)
+}
+
+func TestAppTxJSON(t *testing.T) {
+	privKey := crypto.GenPrivKeyEd25519()
+	appTx := &AppTx{
+		Fee:  1,
+		Gas:  2,
+		Type: 0x05,
+		Input: TxInput{
+			Address:  privKey.PubKey().Address(),
+			Coins:    Coins{{"", 12}},
+			Sequence: 3,
+		},
+		Data: []byte("data1"),
+	}
+	appTx.SetSignature(privKey.Sign(appTx.SignBytes(chainID)))
+	testTxJSONRoundTrip(t, appTx)
+}
+
+func TestKeyJSONUnnamedType(t *testing.T) {
+	// A key type go-crypto may add, that has no name here yet.
+	bz := []byte{0x03, 0xAB, 0xCD}
+	kj := keyToJSON(bz)
+	if kj.Type != "0x03" {
+		t.Errorf("Expected type 0x03, got %v", kj.Type)
+	}
+	decoded, err := keyFromJSON(kj)
+	if err != nil || !bytes.Equal(decoded, bz) {
+		t.Errorf("Expected %X back, got %X: %v", bz, decoded, err)
+	}
+
+	for _, keyType := range []string{"0x01", "0xZZ", "0x0303", "0x"} {
+		if _, err := keyFromJSON(&keyJSON{Type: keyType}); err == nil {
+			t.Errorf("Expected key type %v to be refused", keyType)
+		}
+	}
+}
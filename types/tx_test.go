@@ -62,3 +62,22 @@ func TestAppTxSignable(t *testing.T) {
 		t.Errorf("Got unexpected sign string for AppTx. Expected:\n%v\nGot:\n%v", expected, signBytesHex)
 	}
 }
+
+func TestTxOutputName(t *testing.T) {
+	output := TxOutput{Address: NameRef("alice"), Coins: Coins{{"", 1}}}
+	if name, ok := output.Name(); !ok || name != "alice" {
+		t.Errorf("Expected an output to alice, got %v", output)
+	}
+	if res := output.ValidateBasic(); res.IsErr() {
+		t.Errorf("Unexpected invalid output to a name: %v", res)
+	}
+	if ref := NameRef("a_name_that_is_much_too_long_to_fit"); ref != nil {
+		t.Errorf("Expected no reference to a long name, got %X", ref)
+	}
+
+	// An address is never a name, even if it starts like one.
+	output.Address = []byte("@lice_address_000000")
+	if _, ok := output.Name(); ok {
+		t.Error("Expected a 20 byte address not to be a name")
+	}
+}